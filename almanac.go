@@ -0,0 +1,227 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+/*
+#include "swephexp.h"
+*/
+import "C"
+
+// Almanac is the root node of the rise and set times output. For twilights,
+// rise is the beginning of the dawn and set the end of the dusk.
+type Almanac struct {
+	XMLName    xml.Name    `xml:"almanac"`
+	Events     []RiseTrans `xml:"bodies>Body"`
+	Twilights  []RiseTrans `xml:"twilights>Twilight"`
+	Year       int64       `xml:"year,attr"`
+	Month      int64       `xml:"month,attr"`
+	Day        int64       `xml:"day,attr"`
+	Lat        float64     `xml:"lat,attr"`
+	Lon        float64     `xml:"lon,attr"`
+	Alt        float64     `xml:"alt,attr"`
+	Atpress    float64     `xml:"atpress,attr"`
+	Attemp     float64     `xml:"attemp,attr"`
+	Disc       string      `xml:"disc,attr"`
+	Refraction bool        `xml:"refraction,attr"`
+}
+
+// RiseTrans holds the rise, set and meridian transit times of a body. Status
+// is set to circumpolar or never_rises when the body doesn't cross the
+// horizon that day.
+type RiseTrans struct {
+	XMLName     xml.Name
+	Rise        string `xml:"rise,attr,omitempty"`
+	Set         string `xml:"set,attr,omitempty"`
+	Transit     string `xml:"transit,attr,omitempty"`
+	AntiTransit string `xml:"antitransit,attr,omitempty"`
+	Status      string `xml:"status,attr,omitempty"`
+}
+
+// Settings of swe_rise_trans shared by all the bodies of an almanac
+type riseTransSettings struct {
+	geopos  [3]float64
+	atpress float64
+	attemp  float64
+	flags   int
+}
+
+// Reads the observer and atmospheric conditions from the query string
+func queryRiseTransSettings(q url.Values) riseTransSettings {
+	s := riseTransSettings{
+		geopos:  [3]float64{queryFloat(q, "lon", 0), queryFloat(q, "lat", 0), queryFloat(q, "alt", 0)},
+		atpress: queryFloat(q, "atpress", 1013.25),
+		attemp:  queryFloat(q, "attemp", 10),
+	}
+
+	switch q.Get("disc") {
+	case "center":
+		s.flags |= C.SE_BIT_DISC_CENTER
+	case "bottom":
+		s.flags |= C.SE_BIT_DISC_BOTTOM
+	}
+
+	if q.Get("refraction") == "0" {
+		s.flags |= C.SE_BIT_NO_REFRACTION
+	}
+
+	return s
+}
+
+// Returns the time of an event if it happens before the end of the day
+func eventBefore(jd, end float64) string {
+	if jd == 0 || jd > end {
+		return ""
+	}
+	return utDate(jd)
+}
+
+// bodyRiseTrans computes the events of a body in the day starting at jd.
+// The twilight flags can be used to get the dawn and dusk of the Sun.
+func bodyRiseTrans(jd float64, ipl int, s riseTransSettings, twilight int) (RiseTrans, error) {
+	var rt RiseTrans
+	end := jd + 1
+
+	rise, ok, err := riseTrans(jd, ipl, "", C.SE_CALC_RISE|s.flags|twilight, s.geopos, s.atpress, s.attemp)
+	if err != nil {
+		return rt, err
+	}
+
+	if !ok {
+		// Tell circumpolar bodies from bodies staying below the horizon
+		// using the altitude at the upper culmination
+		transit, _, err := riseTrans(jd, ipl, "", C.SE_CALC_MTRANSIT, s.geopos, s.atpress, s.attemp)
+		if err != nil {
+			return rt, err
+		}
+		xx, err := calcUT(transit, ipl, 0)
+		if err != nil {
+			return rt, err
+		}
+		horizon := 0.
+		switch twilight {
+		case C.SE_BIT_CIVIL_TWILIGHT:
+			horizon = -6
+		case C.SE_BIT_NAUTIC_TWILIGHT:
+			horizon = -12
+		case C.SE_BIT_ASTRO_TWILIGHT:
+			horizon = -18
+		}
+		rt.Status = "never_rises"
+		if azalt(transit, s.geopos, s.atpress, s.attemp, xx[0], xx[1], xx[2])[2] > horizon {
+			rt.Status = "circumpolar"
+		}
+	} else {
+		set, _, err := riseTrans(jd, ipl, "", C.SE_CALC_SET|s.flags|twilight, s.geopos, s.atpress, s.attemp)
+		if err != nil {
+			return rt, err
+		}
+		rt.Rise = eventBefore(rise, end)
+		rt.Set = eventBefore(set, end)
+	}
+
+	if twilight != 0 {
+		return rt, nil
+	}
+
+	transit, _, err := riseTrans(jd, ipl, "", C.SE_CALC_MTRANSIT, s.geopos, s.atpress, s.attemp)
+	if err != nil {
+		return rt, err
+	}
+	antitransit, _, err := riseTrans(jd, ipl, "", C.SE_CALC_ITRANSIT, s.geopos, s.atpress, s.attemp)
+	if err != nil {
+		return rt, err
+	}
+	rt.Transit = eventBefore(transit, end)
+	rt.AntiTransit = eventBefore(antitransit, end)
+
+	return rt, nil
+}
+
+// AlmanacHandler returns the rise, set and meridian transit times of the
+// bodies, and the twilights, for a location and a day. The day starts at the
+// local mean midnight.
+func AlmanacHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	s := queryRiseTransSettings(q)
+
+	a := &Almanac{
+		Year:       queryInt(q, "year", 1970),
+		Month:      queryInt(q, "month", 1),
+		Day:        queryInt(q, "day", 1),
+		Lon:        s.geopos[0],
+		Lat:        s.geopos[1],
+		Alt:        s.geopos[2],
+		Atpress:    s.atpress,
+		Attemp:     s.attemp,
+		Disc:       "limb",
+		Refraction: s.flags&C.SE_BIT_NO_REFRACTION == 0,
+	}
+	if q.Get("disc") == "center" || q.Get("disc") == "bottom" {
+		a.Disc = q.Get("disc")
+	}
+
+	display := []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}
+	if q.Get("display") != "" {
+		d, err := sliceAtoi(strings.Split(q.Get("display"), ","))
+		if err != nil {
+			fmt.Printf("error: %v\n", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		for _, body := range d {
+			if body < 0 || body >= C.SE_NPLANETS {
+				err := fmt.Errorf("unknown body id: %d", body)
+				fmt.Printf("error: %v\n", err)
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		display = d
+	}
+
+	jd := julday(a.Year, a.Month, a.Day, 0) - a.Lon/360
+
+	for _, body := range display {
+		// The Earth doesn't rise from the Earth
+		if body == C.SE_EARTH {
+			continue
+		}
+
+		rt, err := bodyRiseTrans(jd, body, s, 0)
+		if err != nil {
+			fmt.Printf("error: %v\n", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		rt.XMLName = xml.Name{Local: bnames[body]}
+		a.Events = append(a.Events, rt)
+	}
+
+	twilights := []struct {
+		name string
+		flag int
+	}{
+		{"Civil", C.SE_BIT_CIVIL_TWILIGHT},
+		{"Nautical", C.SE_BIT_NAUTIC_TWILIGHT},
+		{"Astronomical", C.SE_BIT_ASTRO_TWILIGHT},
+	}
+
+	for _, t := range twilights {
+		rt, err := bodyRiseTrans(jd, C.SE_SUN, s, t.flag)
+		if err != nil {
+			fmt.Printf("error: %v\n", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		rt.XMLName = xml.Name{Local: t.name}
+		a.Twilights = append(a.Twilights, rt)
+	}
+
+	writeXML(w, a)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAlmanacHandler(t *testing.T) {
+	sweSetEphePath("swe")
+	defer sweClose()
+
+	tests := []struct {
+		name string
+		url  string
+		want []string
+	}{
+		{
+			name: "Paris at the summer solstice",
+			url:  "/almanac?year=2019&month=6&day=21&lat=48.85&lon=2.35&display=0",
+			want: []string{
+				`<Sun rise="2019-06-21T03:46:53Z" set="2019-06-21T19:57:48Z" transit="2019-06-21T11:52:21Z"`,
+				`<Civil rise="2019-06-21T03:04:17Z" set="2019-06-21T20:40:25Z">`,
+				`<Astronomical status="circumpolar">`,
+			},
+		},
+		{
+			name: "Midnight sun",
+			url:  "/almanac?year=2019&month=6&day=21&lat=75&lon=2.35&display=0,1",
+			want: []string{
+				`status="circumpolar"></Sun>`,
+				`status="never_rises"></Moon>`,
+			},
+		},
+		{
+			name: "Earth left out",
+			url:  "/almanac?year=2019&month=6&day=21&lat=48.85&lon=2.35&display=14,15",
+			want: []string{
+				`<Chiron rise=`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("GET", tt.url, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rr := httptest.NewRecorder()
+			handler := http.HandlerFunc(AlmanacHandler)
+			handler.ServeHTTP(rr, req)
+
+			for _, want := range tt.want {
+				if !strings.Contains(rr.Body.String(), want) {
+					t.Errorf("handler returned wrong xml: got %v want %v", rr.Body.String(), want)
+				}
+			}
+		})
+	}
+
+	for _, url := range []string{"/almanac?display=0,23", "/almanac?display=-1", "/almanac?display=Sun"} {
+		req, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(AlmanacHandler).ServeHTTP(rr, req)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%v returned status %v, want %v", url, rr.Code, http.StatusBadRequest)
+		}
+	}
+}
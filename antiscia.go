@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/xml"
+	"math"
+	"net/url"
+)
+
+/*
+#include "swephexp.h"
+*/
+import "C"
+
+// Antiscia is the optional section with the reflections of the bodies
+// across the solstitial and equinoctial axes, and the contacts they make
+type Antiscia struct {
+	Points      []AntisciaPoint   `xml:"points>Point"`
+	Contacts    []AntisciaContact `xml:"contacts>Contact"`
+	Orb         float64           `xml:"orb,attr"`
+	ParallelOrb float64           `xml:"parallel_orb,attr"`
+}
+
+// AntisciaPoint holds the antiscion, mirrored across the Cancer-Capricorn
+// axis, and the contra-antiscion, mirrored across the Aries-Libra axis, of
+// a body. Antiscia share the declination of the body, contra-antiscia have
+// the opposite declination.
+type AntisciaPoint struct {
+	Body                    string  `xml:"body,attr"`
+	Declination             float64 `xml:"declination,attr"`
+	AntiscionSignName       string  `xml:"antiscion_sign_name,attr"`
+	AntiscionDegreeUt       float64 `xml:"antiscion_degree_ut,attr"`
+	AntiscionDegree         float64 `xml:"antiscion_degree,attr"`
+	ContraAntiscionSignName string  `xml:"contra_antiscion_sign_name,attr"`
+	ContraAntiscionDegreeUt float64 `xml:"contra_antiscion_degree_ut,attr"`
+	ContraAntiscionDegree   float64 `xml:"contra_antiscion_degree,attr"`
+}
+
+// AntisciaContact is a body conjunct the antiscion or the contra-antiscion
+// of another body, or a parallel or contra-parallel of declination
+type AntisciaContact struct {
+	XMLName xml.Name
+	Body1   string  `xml:"body1,attr"`
+	Body2   string  `xml:"body2,attr"`
+	Orb     float64 `xml:"orb,attr"`
+}
+
+// antiscion mirrors a longitude across the Cancer-Capricorn axis
+func antiscion(degreeUt float64) float64 {
+	return normalize(180 - degreeUt)
+}
+
+// contraAntiscion mirrors a longitude across the Aries-Libra axis
+func contraAntiscion(degreeUt float64) float64 {
+	return normalize(-degreeUt)
+}
+
+// declination returns the declination of a body of a chart
+func declination(c *ChartInfo, id int) (float64, error) {
+	xx, err := c.bodyPosition(c.julday, id, C.SEFLG_EQUATORIAL)
+	return xx[1], err
+}
+
+// antisciaContacts finds the bodies conjunct the antiscia and the
+// contra-antiscia of the other bodies, and the parallels and contra-parallels.
+// A body on the antiscion of another has that other body on its own
+// antiscion, so each pair is reported once.
+func antisciaContacts(bodies []Body, points []AntisciaPoint, orb, parallelOrb float64) []AntisciaContact {
+	var contacts []AntisciaContact
+
+	add := func(name string, b1, b2 Body, d float64) {
+		contacts = append(contacts, AntisciaContact{xml.Name{Local: name}, b1.XMLName.Local, b2.XMLName.Local, d})
+	}
+
+	for i, b1 := range bodies {
+		for j := i + 1; j < len(bodies); j++ {
+			b2 := bodies[j]
+
+			if d := angleDiff(b1.DegreeUt, points[j].AntiscionDegreeUt); d <= orb {
+				add("Antiscion", b1, b2, d)
+			}
+			if d := angleDiff(b1.DegreeUt, points[j].ContraAntiscionDegreeUt); d <= orb {
+				add("ContraAntiscion", b1, b2, d)
+			}
+
+			if parallelOrb <= 0 {
+				continue
+			}
+			if d := math.Abs(points[i].Declination - points[j].Declination); d <= parallelOrb {
+				add("Parallel", b1, b2, d)
+			}
+			if d := math.Abs(points[i].Declination + points[j].Declination); d <= parallelOrb {
+				add("ContraParallel", b1, b2, d)
+			}
+		}
+	}
+
+	return contacts
+}
+
+// addAntiscia adds the antiscia section to a chart. Contacts use
+// antiscia_orb, parallels of declination use parallel_orb, 0 disables them.
+func addAntiscia(c *ChartInfo, q url.Values) error {
+	a := &Antiscia{
+		Orb:         queryFloat(q, "antiscia_orb", 1),
+		ParallelOrb: queryFloat(q, "parallel_orb", 1),
+	}
+
+	var bodies []Body
+	for _, b := range c.Bodies {
+		if b.XMLName.Local == "Earth" {
+			continue
+		}
+
+		dec, err := declination(c, b.ID)
+		if err != nil {
+			return err
+		}
+
+		ant := antiscion(b.DegreeUt)
+		contra := contraAntiscion(b.DegreeUt)
+		antSign, antDegree := signOf(ant)
+		contraSign, contraDegree := signOf(contra)
+
+		a.Points = append(a.Points, AntisciaPoint{
+			Body:                    b.XMLName.Local,
+			Declination:             dec,
+			AntiscionSignName:       snames[antSign],
+			AntiscionDegreeUt:       ant,
+			AntiscionDegree:         antDegree,
+			ContraAntiscionSignName: snames[contraSign],
+			ContraAntiscionDegreeUt: contra,
+			ContraAntiscionDegree:   contraDegree,
+		})
+		bodies = append(bodies, b)
+	}
+
+	a.Contacts = antisciaContacts(bodies, a.Points, a.Orb, a.ParallelOrb)
+	c.Antiscia = a
+
+	return nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func Test_antiscion(t *testing.T) {
+	tests := []struct {
+		name       string
+		degreeUt   float64
+		want       float64
+		wantContra float64
+	}{
+		{name: "Aries 10°", degreeUt: 10, want: 170, wantContra: 350},
+		{name: "Cancer 0°", degreeUt: 90, want: 90, wantContra: 270},
+		{name: "Capricorn 15°", degreeUt: 285, want: 255, wantContra: 75},
+		{name: "Libra 0°", degreeUt: 180, want: 0, wantContra: 180},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := antiscion(tt.degreeUt); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("antiscion() = %v, want %v", got, tt.want)
+			}
+			if got := contraAntiscion(tt.degreeUt); math.Abs(got-tt.wantContra) > 1e-9 {
+				t.Errorf("contraAntiscion() = %v, want %v", got, tt.wantContra)
+			}
+		})
+	}
+}
+
+func TestChartInfoHandlerAntiscia(t *testing.T) {
+	sweSetEphePath("swe")
+	defer sweClose()
+
+	req, err := http.NewRequest("GET", "/chartinfo?year=2019&month=2&day=18&time=16.083334&lat=48&lon=2&display=0,1,2,3,4,5,6,7,8,9&antiscia=1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(ChartInfoHandler)
+	handler.ServeHTTP(rr, req)
+
+	for _, want := range []string{
+		`<antiscia orb="1" parallel_orb="1">`,
+		`<Point body="Sun" declination="-11.57`,
+		`<ContraAntiscion body1="Uranus" body2="Sun"`,
+		`<Parallel body1="Mercury" body2="Neptune"`,
+	} {
+		if !strings.Contains(rr.Body.String(), want) {
+			t.Errorf("handler returned wrong xml: got %v want %v", rr.Body.String(), want)
+		}
+	}
+}
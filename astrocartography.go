@@ -0,0 +1,267 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+)
+
+/*
+#include "swephexp.h"
+*/
+import "C"
+
+// FeatureCollection is the root object of a GeoJSON document
+type FeatureCollection struct {
+	Type     string    `json:"type"`
+	Features []Feature `json:"features"`
+}
+
+// Feature is a GeoJSON feature, a geometry with its properties
+type Feature struct {
+	Type       string            `json:"type"`
+	Geometry   Geometry          `json:"geometry"`
+	Properties map[string]string `json:"properties"`
+}
+
+// Geometry is a GeoJSON point or line string, with [lon, lat] coordinates
+type Geometry struct {
+	Type        string      `json:"type"`
+	Coordinates interface{} `json:"coordinates"`
+}
+
+// The angles of the astrocartography lines
+var acgAngles = []string{"ASC", "DSC", "MC", "IC"}
+
+// The finest sampling of the lines, in degrees of latitude
+const minLatStep = 0.1
+
+// acgLine is the line of the places where a body or a star is on an angle
+type acgLine struct {
+	name  string
+	star  bool
+	angle string
+	ra    float64
+	dec   float64
+	gst   float64
+}
+
+// lon returns the geographic longitude of the line at a latitude. The bool
+// is false when the body neither rises nor sets at that latitude.
+func (l acgLine) lon(lat float64) (float64, bool) {
+	switch l.angle {
+	case "MC":
+		return normalize180(l.ra - l.gst), true
+	case "IC":
+		return normalize180(l.ra + 180 - l.gst), true
+	}
+
+	x := -math.Tan(lat*deg) * math.Tan(l.dec*deg)
+	if math.Abs(x) > 1 {
+		return 0, false
+	}
+	h := math.Acos(x) / deg
+	if l.angle == "ASC" {
+		h = -h
+	}
+	return normalize180(l.ra + h - l.gst), true
+}
+
+// latitudes returns the latitudes sampled from -max to max
+func latitudes(max, step float64) []float64 {
+	var lats []float64
+	for i := 0; -max+float64(i)*step <= max; i++ {
+		lats = append(lats, -max+float64(i)*step)
+	}
+	return lats
+}
+
+// lineFeatures draws a line as GeoJSON line strings, split where the body
+// stops rising or setting and where the line crosses the antimeridian
+func lineFeatures(l acgLine, lats []float64) []Feature {
+	var features []Feature
+	var coords [][2]float64
+
+	flush := func() {
+		if len(coords) > 1 {
+			features = append(features, Feature{
+				Type:       "Feature",
+				Geometry:   Geometry{Type: "LineString", Coordinates: coords},
+				Properties: map[string]string{"body": l.name, "angle": l.angle},
+			})
+		}
+		coords = nil
+	}
+
+	for _, lat := range lats {
+		lon, ok := l.lon(lat)
+		if !ok {
+			flush()
+			continue
+		}
+		if len(coords) > 0 && math.Abs(lon-coords[len(coords)-1][0]) > 180 {
+			flush()
+		}
+		coords = append(coords, [2]float64{lon, lat})
+	}
+	flush()
+
+	return features
+}
+
+// parans finds where two lines cross between the sampled latitudes, places
+// where both bodies are on an angle at the same moment
+func parans(a, b acgLine, lats []float64) []Feature {
+	diff := func(lat float64) (float64, bool) {
+		la, ok := a.lon(lat)
+		if !ok {
+			return 0, false
+		}
+		lb, ok := b.lon(lat)
+		return normalize180(la - lb), ok
+	}
+
+	var features []Feature
+	for i := 1; i < len(lats); i++ {
+		d0, ok0 := diff(lats[i-1])
+		d1, ok1 := diff(lats[i])
+		if !ok0 || !ok1 || math.Abs(d0) > 90 || math.Abs(d1) > 90 || (d0 < 0) == (d1 < 0) {
+			continue
+		}
+
+		lo, hi := lats[i-1], lats[i]
+		for hi-lo > 1e-9 {
+			mid := (lo + hi) / 2
+			d, ok := diff(mid)
+			if !ok {
+				break
+			}
+			if (d < 0) == (d0 < 0) {
+				lo = mid
+			} else {
+				hi = mid
+			}
+		}
+
+		lon, _ := a.lon(lo)
+		features = append(features, Feature{
+			Type:     "Feature",
+			Geometry: Geometry{Type: "Point", Coordinates: [2]float64{lon, lo}},
+			Properties: map[string]string{"kind": "paran", "body1": a.name, "angle1": a.angle,
+				"body2": b.name, "angle2": b.angle},
+		})
+	}
+
+	return features
+}
+
+// astrocartography computes the lines of the bodies at a moment, and the
+// parans of the bodies between themselves and with the stars
+func astrocartography(jd float64, bodies []int, stars []string, lats []float64) (*FeatureCollection, error) {
+	gst := sidTime(jd) * 15
+
+	var lines []acgLine
+	for _, ipl := range bodies {
+		xx, err := calcUT(jd, ipl, C.SEFLG_EQUATORIAL)
+		if err != nil {
+			return nil, err
+		}
+		for _, angle := range acgAngles {
+			lines = append(lines, acgLine{name: bnames[ipl], angle: angle, ra: xx[0], dec: xx[1], gst: gst})
+		}
+	}
+	for _, star := range stars {
+		xx, name, err := fixstarUT(star, jd, C.SEFLG_EQUATORIAL)
+		if err != nil {
+			return nil, err
+		}
+		name = strings.Split(name, ",")[0]
+		for _, angle := range acgAngles {
+			lines = append(lines, acgLine{name: name, star: true, angle: angle, ra: xx[0], dec: xx[1], gst: gst})
+		}
+	}
+
+	fc := &FeatureCollection{Type: "FeatureCollection", Features: []Feature{}}
+	for _, l := range lines {
+		if !l.star {
+			fc.Features = append(fc.Features, lineFeatures(l, lats)...)
+		}
+	}
+	for i, a := range lines {
+		for _, b := range lines[i+1:] {
+			if a.name == b.name || (a.star && b.star) {
+				continue
+			}
+			fc.Features = append(fc.Features, parans(a, b, lats)...)
+		}
+	}
+
+	return fc, nil
+}
+
+// writeJSON marshals v and writes it as a json document
+func writeJSON(w http.ResponseWriter, contentType string, v interface{}) {
+	out, err := json.Marshal(v)
+	if err != nil {
+		fmt.Printf("error: %v\n", err)
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Write(out)
+}
+
+// AstrocartographyHandler returns the astrocartography lines of a moment as
+// GeoJSON line strings, with the parans as points. The bodies are given by
+// display, the planets by default, and the stars of the parans by stars.
+// The lines are sampled every lat_step degrees, at least minLatStep, up to
+// lat_max.
+func AstrocartographyHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	bodies := []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}
+	if q.Get("display") != "" {
+		d, err := planetIDs(q.Get("display"))
+		if err != nil {
+			fmt.Printf("error: %v\n", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		bodies = d
+	}
+
+	var stars []string
+	if q.Get("stars") != "" {
+		stars = strings.Split(q.Get("stars"), ",")
+	}
+
+	step := queryFloat(q, "lat_step", 1)
+	if step < minLatStep {
+		err := fmt.Errorf("lat_step must be at least %v", minLatStep)
+		fmt.Printf("error: %v\n", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	latMax := queryFloat(q, "lat_max", 85)
+	if latMax <= 0 {
+		err := errors.New("lat_max must be positive")
+		fmt.Printf("error: %v\n", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	lats := latitudes(math.Min(latMax, 89.9), step)
+
+	jd := julday(queryInt(q, "year", 1970), queryInt(q, "month", 1),
+		queryInt(q, "day", 1), queryFloat(q, "time", 0))
+
+	fc, err := astrocartography(jd, bodies, stars, lats)
+	if err != nil {
+		fmt.Printf("error: %v\n", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writeJSON(w, "application/geo+json", fc)
+}
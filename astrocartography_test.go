@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_acgLine_lon(t *testing.T) {
+	tests := []struct {
+		name   string
+		line   acgLine
+		lat    float64
+		want   float64
+		wantOk bool
+	}{
+		{name: "MC", line: acgLine{angle: "MC", ra: 100, gst: 40}, lat: 50, want: 60, wantOk: true},
+		{name: "IC", line: acgLine{angle: "IC", ra: 100, gst: 40}, lat: 50, want: -120, wantOk: true},
+		{name: "ASC on the equator", line: acgLine{angle: "ASC", ra: 100, dec: 20, gst: 40}, lat: 0, want: -30, wantOk: true},
+		{name: "DSC of the equator", line: acgLine{angle: "DSC", ra: 100, gst: 40}, lat: 60, want: 150, wantOk: true},
+		{name: "Circumpolar", line: acgLine{angle: "ASC", ra: 100, dec: 20, gst: 40}, lat: 75},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.line.lon(tt.lat)
+			if ok != tt.wantOk || (ok && math.Abs(got-tt.want) > 1e-9) {
+				t.Errorf("acgLine.lon() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func Test_lineFeatures(t *testing.T) {
+	// The MC line runs through every latitude, the ascendant line of a body
+	// far north stops short of the arctic latitudes
+	lats := latitudes(80, 10)
+	mc := lineFeatures(acgLine{name: "Sun", angle: "MC", ra: 10, gst: 190}, lats)
+	if len(mc) != 1 || len(mc[0].Geometry.Coordinates.([][2]float64)) != len(lats) {
+		t.Errorf("lineFeatures() MC = %v, want one line through every latitude", mc)
+	}
+
+	asc := lineFeatures(acgLine{name: "Moon", angle: "ASC", ra: 10, dec: 28, gst: 190}, lats)
+	for _, f := range asc {
+		for _, c := range f.Geometry.Coordinates.([][2]float64) {
+			if c[1] > 62 {
+				t.Errorf("lineFeatures() ASC reaches latitude %v", c[1])
+			}
+		}
+	}
+}
+
+func Test_parans(t *testing.T) {
+	mc := acgLine{name: "Sun", angle: "MC", ra: 100, gst: 40}
+	asc := acgLine{name: "Regulus", angle: "ASC", ra: 170, dec: 12, gst: 40}
+	ps := parans(mc, asc, latitudes(80, 1))
+	if len(ps) != 1 {
+		t.Fatalf("parans() = %v, want one crossing", ps)
+	}
+	c := ps[0].Geometry.Coordinates.([2]float64)
+	if lon, _ := asc.lon(c[1]); math.Abs(lon-60) > 1e-6 || math.Abs(c[0]-60) > 1e-9 {
+		t.Errorf("parans() crossing at %v, ascendant line at %v", c, lon)
+	}
+}
+
+func TestAstrocartographyHandler(t *testing.T) {
+	sweSetEphePath("swe")
+	defer sweClose()
+
+	req := httptest.NewRequest("GET", "/astrocartography?year=1980&month=5&day=17&time=12&display=0,1&stars=Regulus", nil)
+	w := httptest.NewRecorder()
+	AstrocartographyHandler(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("AstrocartographyHandler() status = %v", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/geo+json" {
+		t.Errorf("AstrocartographyHandler() content type = %v", ct)
+	}
+
+	var fc struct {
+		Type     string
+		Features []struct {
+			Geometry struct {
+				Type        string
+				Coordinates json.RawMessage
+			}
+			Properties map[string]string
+		}
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &fc); err != nil {
+		t.Fatal(err)
+	}
+
+	lines, starParans := 0, 0
+	for _, f := range fc.Features {
+		p := f.Properties
+		if f.Geometry.Type == "LineString" {
+			lines++
+			if p["body"] == "Regulus" {
+				t.Errorf("AstrocartographyHandler() drew the line of a star")
+			}
+		}
+		if f.Geometry.Type == "Point" && p["kind"] == "paran" && p["body2"] == "Regulus" {
+			starParans++
+		}
+	}
+	if fc.Type != "FeatureCollection" || lines < 8 || starParans == 0 {
+		t.Errorf("AstrocartographyHandler() = %d lines and %d star parans", lines, starParans)
+	}
+
+	req = httptest.NewRequest("GET", "/astrocartography?stars=Nostar", nil)
+	w = httptest.NewRecorder()
+	AstrocartographyHandler(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("AstrocartographyHandler() status = %v for an unknown star", w.Code)
+	}
+
+	for _, query := range []string{"display=0,40", "lat_step=1e-9", "lat_step=0", "lat_max=0"} {
+		req = httptest.NewRequest("GET", "/astrocartography?"+query, nil)
+		w = httptest.NewRecorder()
+		AstrocartographyHandler(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("AstrocartographyHandler() status = %v for %v", w.Code, query)
+		}
+	}
+}
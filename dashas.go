@@ -0,0 +1,346 @@
+package main
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+)
+
+// Dashas is the root node of the dasha periods output
+type Dashas struct {
+	XMLName       xml.Name `xml:"dashas"`
+	Current       *Current `xml:"current,omitempty"`
+	Periods       []Period `xml:",any"`
+	System        string   `xml:"system,attr"`
+	Ayanamsa      string   `xml:"ayanamsa,attr"`
+	MoonNakshatra string   `xml:"moon_nakshatra,attr,omitempty"`
+	Balance       float64  `xml:"balance,attr,omitempty"`
+	YearLength    float64  `xml:"year_length,attr"`
+	Year          int64    `xml:"year,attr"`
+	Month         int64    `xml:"month,attr"`
+	Day           int64    `xml:"day,attr"`
+	Time          float64  `xml:"time,attr"`
+	Lat           float64  `xml:"lat,attr"`
+	Lon           float64  `xml:"lon,attr"`
+}
+
+// Current holds the periods running at a date
+type Current struct {
+	Date    string   `xml:"date,attr"`
+	Periods []Period `xml:",any"`
+}
+
+// Period is a mahadasha, an antardasha or a pratyantardasha. Planetary
+// dashas have a lord, the Yogini dasha also names the yogini and the Chara
+// dasha gives a sign.
+type Period struct {
+	XMLName xml.Name
+	Lord    string   `xml:"lord,attr,omitempty"`
+	Yogini  string   `xml:"yogini,attr,omitempty"`
+	Sign    string   `xml:"sign,attr,omitempty"`
+	Start   string   `xml:"start,attr"`
+	End     string   `xml:"end,attr"`
+	Years   float64  `xml:"years,attr"`
+	Periods []Period `xml:",any"`
+	start   float64
+	end     float64
+}
+
+// Names of the levels of the dasha periods
+var dashaLevels = []string{"Mahadasha", "Antardasha", "Pratyantardasha"}
+
+type dashaLord struct {
+	yogini string
+	lord   string
+	years  float64
+}
+
+// The Vimshottari dasha cycle of 120 years, starting with the lord of Ashwini
+var vimshottari = []dashaLord{
+	{"", "Ketu", 7}, {"", "Venus", 20}, {"", "Sun", 6}, {"", "Moon", 10},
+	{"", "Mars", 7}, {"", "Rahu", 18}, {"", "Jupiter", 16}, {"", "Saturn", 19},
+	{"", "Mercury", 17},
+}
+
+// The Yogini dasha cycle of 36 years
+var yoginis = []dashaLord{
+	{"Mangala", "Moon", 1}, {"Pingala", "Sun", 2}, {"Dhanya", "Jupiter", 3},
+	{"Bhramari", "Mars", 4}, {"Bhadrika", "Mercury", 5}, {"Ulka", "Saturn", 6},
+	{"Siddha", "Venus", 7}, {"Sankata", "Rahu", 8},
+}
+
+// The signs counted forward in the Chara dasha, the others are counted
+// backward
+var savyaSigns = []bool{true, true, true, false, false, false, true, true, true, false, false, false}
+
+// cyclePeriods divides length days starting at start between the lords of
+// a cycle, beginning with lords[first], down to the given number of levels.
+// Each period is divided again between all the lords, beginning with its own.
+func cyclePeriods(lords []dashaLord, first int, start, length, yearLength float64, level, levels int) []Period {
+	var total float64
+	for _, l := range lords {
+		total += l.years
+	}
+
+	var periods []Period
+	for i := 0; i < len(lords); i++ {
+		n := (first + i) % len(lords)
+		p := Period{
+			XMLName: xml.Name{Local: dashaLevels[level]},
+			Lord:    lords[n].lord,
+			Yogini:  lords[n].yogini,
+			start:   start,
+			end:     start + length*lords[n].years/total,
+		}
+		p.Start, p.End, p.Years = utDate(p.start), utDate(p.end), (p.end-p.start)/yearLength
+		if level+1 < levels {
+			p.Periods = cyclePeriods(lords, n, p.start, p.end-p.start, yearLength, level+1, levels)
+		}
+		periods = append(periods, p)
+		start = p.end
+	}
+
+	return periods
+}
+
+// nakshatraDashas computes the Vimshottari or Yogini dashas from the
+// nakshatra of the Moon. The first period is only partly run at birth, the
+// balance is the fraction of it left.
+func nakshatraDashas(d *Dashas, lords []dashaLord, offset int, jd, moon float64, levels int) {
+	n := int(normalize(moon) / nakshatraSpan)
+	elapsed := math.Mod(normalize(moon), nakshatraSpan) / nakshatraSpan
+	first := (n + offset) % len(lords)
+
+	var total float64
+	for _, l := range lords {
+		total += l.years
+	}
+
+	d.MoonNakshatra = nakshatras[n]
+	d.Balance = 1 - elapsed
+	start := jd - elapsed*lords[first].years*d.YearLength
+	d.Periods = cyclePeriods(lords, first, start, total*d.YearLength, d.YearLength, 0, levels)
+}
+
+// charaLord returns the lord of a sign for the Chara dasha. Scorpio and
+// Aquarius have two lords, the stronger is used: the one outside the sign,
+// else the one with the most planets, else the traditional one.
+func charaLord(sign int, signs map[string]int) string {
+	lord := domiciles[sign]
+	coLord := map[int]string{7: "Ketu", 10: "Rahu"}[sign]
+	if coLord == "" {
+		return lord
+	}
+
+	if signs[lord] == sign && signs[coLord] != sign {
+		return coLord
+	}
+	if signs[coLord] == sign && signs[lord] != sign {
+		return lord
+	}
+
+	count := func(p string) int {
+		n := 0
+		for _, s := range signs {
+			if s == signs[p] {
+				n++
+			}
+		}
+		return n
+	}
+	if count(coLord) > count(lord) {
+		return coLord
+	}
+	return lord
+}
+
+// charaYears returns the number of years of the Chara dasha of a sign: the
+// count from the sign to its lord, plus one year for an exalted lord and
+// minus one for a debilitated lord
+func charaYears(sign int, signs map[string]int) float64 {
+	lord := charaLord(sign, signs)
+	ls := signs[lord]
+
+	n := (ls - sign + 12) % 12
+	if !savyaSigns[sign] {
+		n = (sign - ls + 12) % 12
+	}
+	if n == 0 {
+		n = 12
+	}
+
+	if exaltations[ls] == lord {
+		n++
+	}
+	if exaltations[(ls+6)%12] == lord {
+		n--
+	}
+
+	return float64(n)
+}
+
+// equalPeriods divides a Chara dasha period in twelve equal sub-periods,
+// beginning with the next sign in the direction of the dasha
+func equalPeriods(sign, step int, start, length, yearLength float64, level, levels int) []Period {
+	var periods []Period
+	for i := 1; i <= 12; i++ {
+		s := ((sign+i*step)%12 + 12) % 12
+		p := Period{
+			XMLName: xml.Name{Local: dashaLevels[level]},
+			Sign:    snames[s],
+			start:   start,
+			end:     start + length/12,
+		}
+		p.Start, p.End, p.Years = utDate(p.start), utDate(p.end), (p.end-p.start)/yearLength
+		if level+1 < levels {
+			p.Periods = equalPeriods(s, step, p.start, length/12, yearLength, level+1, levels)
+		}
+		periods = append(periods, p)
+		start = p.end
+	}
+	return periods
+}
+
+// charaDashas computes the Jaimini Chara dashas from the sign of the
+// ascendant. The signs follow each other forward when the ninth sign from
+// the ascendant is counted forward, backward otherwise. The second cycle
+// gives each sign the years it lacked to twelve in the first one.
+func charaDashas(d *Dashas, jd float64, points map[string]float64, asc float64, levels int) {
+	signs := make(map[string]int)
+	for _, p := range traditionalPlanets {
+		signs[p], _ = signOf(points[p])
+	}
+	signs["Rahu"], _ = signOf(points["MeanNode"])
+	signs["Ketu"], _ = signOf(points["MeanSouthNode"])
+
+	lagna, _ := signOf(asc)
+	step := 1
+	if !savyaSigns[(lagna+8)%12] {
+		step = -1
+	}
+
+	start := jd
+	for cycle := 0; cycle < 2; cycle++ {
+		for i := 0; i < 12; i++ {
+			s := ((lagna+i*step)%12 + 12) % 12
+			years := charaYears(s, signs)
+			if cycle == 1 {
+				years = 12 - years
+			}
+			if years <= 0 {
+				continue
+			}
+
+			p := Period{
+				XMLName: xml.Name{Local: dashaLevels[0]},
+				Sign:    snames[s],
+				start:   start,
+				end:     start + years*d.YearLength,
+			}
+			p.Start, p.End, p.Years = utDate(p.start), utDate(p.end), years
+			if levels > 1 {
+				p.Periods = equalPeriods(s, step, p.start, p.end-p.start, d.YearLength, 1, levels)
+			}
+			d.Periods = append(d.Periods, p)
+			start = p.end
+		}
+	}
+}
+
+// currentPeriods returns the periods running at jd, one per level
+func currentPeriods(periods []Period, jd float64) []Period {
+	for _, p := range periods {
+		if jd >= p.start && jd < p.end {
+			sub := p.Periods
+			p.Periods = nil
+			return append([]Period{p}, currentPeriods(sub, jd)...)
+		}
+	}
+	return nil
+}
+
+// queryDashaChart casts the sidereal birth chart of a dasha query, using
+// the Lahiri ayanamsa by default
+func queryDashaChart(q url.Values) (*ChartInfo, error) {
+	if q.Get("ayanamsa") == "" {
+		q.Set("ayanamsa", "lahiri")
+	}
+	q.Set("display", "0,1")
+
+	c, display, err := parseChartInfo(q, "")
+	if err != nil {
+		return nil, err
+	}
+	if err := castChart(c, display); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// DashasHandler returns the Vimshottari, Yogini or Chara dasha periods of a
+// birth chart with system=vimshottari, yogini or chara, down to the given
+// number of levels. The periods running at a date are given with as_of_year,
+// as_of_month, as_of_day and as_of_time.
+func DashasHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	c, err := queryDashaChart(q)
+	if err != nil {
+		fmt.Printf("error: %v\n", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	points, err := chartPoints(c)
+	if err != nil {
+		fmt.Printf("error: %v\n", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	d := &Dashas{
+		System:     q.Get("system"),
+		Ayanamsa:   c.Ayanamsa,
+		YearLength: queryFloat(q, "year_length", 365.25),
+		Year:       c.Year,
+		Month:      c.Month,
+		Day:        c.Day,
+		Time:       c.Time,
+		Lat:        c.Lat,
+		Lon:        c.Lon,
+	}
+	if d.System == "" {
+		d.System = "vimshottari"
+	}
+
+	levels := int(queryInt(q, "levels", 3))
+	if levels < 1 || levels > len(dashaLevels) {
+		levels = len(dashaLevels)
+	}
+
+	switch d.System {
+	case "vimshottari":
+		nakshatraDashas(d, vimshottari, 0, c.julday, points["Moon"], levels)
+	case "yogini":
+		// Ashwini, the first nakshatra, starts with Bhramari
+		nakshatraDashas(d, yoginis, 3, c.julday, points["Moon"], levels)
+	case "chara":
+		charaDashas(d, c.julday, points, c.ascmc[0], levels)
+	default:
+		err := errors.New("unknown dasha system: " + d.System)
+		fmt.Printf("error: %v\n", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if q.Get("as_of_year") != "" {
+		jd := julday(queryInt(q, "as_of_year", 1970), queryInt(q, "as_of_month", 1),
+			queryInt(q, "as_of_day", 1), queryFloat(q, "as_of_time", 0))
+		d.Current = &Current{Date: utDate(jd), Periods: currentPeriods(d.Periods, jd)}
+	}
+
+	writeXML(w, d)
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func Test_cyclePeriods(t *testing.T) {
+	periods := cyclePeriods(vimshottari, 8, 0, 120*365.25, 365.25, 0, 2)
+
+	if len(periods) != 9 || periods[0].Lord != "Mercury" || periods[1].Lord != "Ketu" {
+		t.Fatalf("cyclePeriods() = %v, want 9 periods starting with Mercury and Ketu", periods)
+	}
+	if math.Abs(periods[0].Years-17) > 1e-9 {
+		t.Errorf("cyclePeriods() years = %v, want 17", periods[0].Years)
+	}
+
+	antar := periods[0].Periods
+	if antar[0].Lord != "Mercury" || antar[8].Lord != "Saturn" {
+		t.Errorf("cyclePeriods() antardashas from %v to %v, want Mercury to Saturn", antar[0].Lord, antar[8].Lord)
+	}
+	if math.Abs(antar[0].Years-17*17.0/120) > 1e-9 {
+		t.Errorf("cyclePeriods() antardasha years = %v, want %v", antar[0].Years, 17*17.0/120)
+	}
+	if antar[8].end != periods[0].end {
+		t.Errorf("cyclePeriods() last antardasha ends at %v, want %v", antar[8].end, periods[0].end)
+	}
+}
+
+func Test_charaYears(t *testing.T) {
+	tests := []struct {
+		name  string
+		sign  int
+		signs map[string]int
+		want  float64
+	}{
+		{name: "Lord in its own sign", sign: 3, signs: map[string]int{"Moon": 3}, want: 12},
+		{name: "Counted forward", sign: 0, signs: map[string]int{"Mars": 4}, want: 4},
+		{name: "Counted backward", sign: 4, signs: map[string]int{"Sun": 2}, want: 2},
+		{name: "Exalted lord", sign: 6, signs: map[string]int{"Venus": 11}, want: 6},
+		{name: "Co-lord of Scorpio", sign: 7, signs: map[string]int{"Mars": 7, "Ketu": 9}, want: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := charaYears(tt.sign, tt.signs); got != tt.want {
+				t.Errorf("charaYears() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDashasHandler(t *testing.T) {
+	sweSetEphePath("swe")
+	defer sweClose()
+
+	tests := []struct {
+		name string
+		url  string
+		want []string
+	}{
+		{
+			name: "Vimshottari",
+			url:  "/dashas?year=2019&month=2&day=18&time=16.083334&lat=48&lon=2&as_of_year=2020",
+			want: []string{
+				`moon_nakshatra="Ashlesha"`,
+				`<Mahadasha lord="Mercury" start="2013-01-14T05:55:50Z" end="2030-01-14T11:55:50Z" years="17">`,
+				`<current date="2020-01-01T00:00:00Z">`,
+				`<Pratyantardasha lord=`,
+			},
+		},
+		{
+			name: "Yogini",
+			url:  "/dashas?year=2019&month=2&day=18&time=16.083334&lat=48&lon=2&system=yogini&levels=1",
+			want: []string{`<Mahadasha lord="Mars" yogini="Bhramari" start="2017-09-12T19:20:29Z"`},
+		},
+		{
+			name: "Chara",
+			url:  "/dashas?year=2019&month=2&day=18&time=16.083334&lat=48&lon=2&system=chara&levels=2",
+			want: []string{
+				`<Mahadasha sign="Cancer" start="2019-02-18T16:05:00Z" end="2031-02-18T16:05:00Z" years="12">`,
+				`<Antardasha sign="Gemini" start="2019-02-18T16:05:00Z"`,
+			},
+		},
+		{
+			name: "Unknown system",
+			url:  "/dashas?system=ashtottari",
+			want: []string{"unknown dasha system: ashtottari"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("GET", tt.url, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rr := httptest.NewRecorder()
+			handler := http.HandlerFunc(DashasHandler)
+			handler.ServeHTTP(rr, req)
+
+			for _, want := range tt.want {
+				if !strings.Contains(rr.Body.String(), want) {
+					t.Errorf("handler returned wrong xml: got %v want %v", rr.Body.String(), want)
+				}
+			}
+		})
+	}
+}
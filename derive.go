@@ -0,0 +1,132 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"net/url"
+	"strconv"
+)
+
+/*
+#include "swephexp.h"
+*/
+import "C"
+
+// parseDerive reads the derived chart parameters from a query string.
+// derive=harmonic uses harmonic=N, derive=draconic uses node=mean or true,
+// derive=90 and derive=45 project the chart from a 90° or 45° dial.
+func parseDerive(q url.Values, prefix string, c *ChartInfo) error {
+	c.Derive = q.Get(prefix + "derive")
+
+	switch c.Derive {
+	case "", "90", "45":
+	case "harmonic":
+		c.Harmonic = queryInt(q, prefix+"harmonic", 1)
+		if c.Harmonic < 1 {
+			return errors.New("the harmonic must be a positive integer")
+		}
+	case "draconic":
+		c.DraconicNode = "TrueNode"
+		if q.Get(prefix+"node") == "mean" {
+			c.DraconicNode = "MeanNode"
+		}
+	default:
+		return errors.New("unknown derived chart: " + c.Derive)
+	}
+
+	return nil
+}
+
+// deriveTransform returns the function mapping the longitudes of a chart to
+// the longitudes of its derived chart
+func deriveTransform(c *ChartInfo) (func(float64) float64, error) {
+	switch c.Derive {
+	case "harmonic":
+		if c.Harmonic < 1 {
+			return nil, errors.New("the harmonic must be a positive integer")
+		}
+		n := float64(c.Harmonic)
+		return func(lon float64) float64 { return normalize(lon * n) }, nil
+
+	case "draconic":
+		ipl := C.SE_TRUE_NODE
+		if c.DraconicNode == "MeanNode" {
+			ipl = C.SE_MEAN_NODE
+		}
+		xx, err := calcUT(c.julday, ipl, 0)
+		if err != nil {
+			return nil, err
+		}
+		// The longitudes it shifts are already sidereal in a sidereal chart
+		node := c.derived(xx[0])
+		return func(lon float64) float64 { return normalize(lon - node) }, nil
+
+	case "90", "45":
+		size, _ := strconv.ParseFloat(c.Derive, 64)
+		return func(lon float64) float64 { return math.Mod(normalize(lon), size) * 360 / size }, nil
+	}
+
+	return nil, errors.New("unknown derived chart: " + c.Derive)
+}
+
+// deriveChart replaces the angles, the house cusps and the bodies of a
+// chart by those of its derived chart
+func deriveChart(c *ChartInfo) error {
+	f, err := deriveTransform(c)
+	if err != nil {
+		return err
+	}
+	transformChart(c, f)
+	return nil
+}
+
+// transformChart maps the longitudes of the angles, the house cusps and the
+// bodies of a chart with f, after any previous transformation. The ARMC is a
+// right ascension and is left as is.
+func transformChart(c *ChartInfo, f func(float64) float64) {
+	if prev := c.transform; prev != nil {
+		c.transform = func(lon float64) float64 { return f(prev(lon)) }
+	} else {
+		c.transform = f
+	}
+
+	for i := range c.ascmc {
+		if i != C.SE_ARMC {
+			c.ascmc[i] = f(c.ascmc[i])
+		}
+	}
+	for i := 1; i < len(c.cusps); i++ {
+		c.cusps[i] = f(c.cusps[i])
+	}
+
+	for i, a := range c.AscMCs {
+		if a.ID-1 == C.SE_ARMC {
+			continue
+		}
+		a.DegreeUt = f(a.DegreeUt)
+		a.Sign, a.Degree = signOf(a.DegreeUt)
+		a.SignName = snames[a.Sign]
+		c.AscMCs[i] = a
+	}
+	for i, h := range c.Houses {
+		h.DegreeUt = f(h.DegreeUt)
+		h.Sign, h.Degree = signOf(h.DegreeUt)
+		h.SignName = snames[h.Sign]
+		c.Houses[i] = h
+	}
+	for i, b := range c.Bodies {
+		b.DegreeUt = f(b.DegreeUt)
+		b.Sign, b.Degree = signOf(b.DegreeUt)
+		b.SignName = snames[b.Sign]
+		c.Bodies[i] = b
+	}
+}
+
+// derived maps a longitude computed from the ephemeris into the derived
+// chart, if any
+func (c *ChartInfo) derived(lon float64) float64 {
+	if c.transform == nil {
+		return lon
+	}
+	return c.transform(lon)
+}
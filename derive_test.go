@@ -0,0 +1,105 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func Test_deriveTransform(t *testing.T) {
+	tests := []struct {
+		name    string
+		c       *ChartInfo
+		lon     float64
+		want    float64
+		wantErr bool
+	}{
+		{name: "Fifth harmonic", c: &ChartInfo{Derive: "harmonic", Harmonic: 5}, lon: 100, want: 140},
+		{name: "Seventh harmonic", c: &ChartInfo{Derive: "harmonic", Harmonic: 7}, lon: 10, want: 70},
+		{name: "90° projection", c: &ChartInfo{Derive: "90"}, lon: 100, want: 40},
+		{name: "45° projection", c: &ChartInfo{Derive: "45"}, lon: 50, want: 40},
+		{name: "Zero harmonic", c: &ChartInfo{Derive: "harmonic"}, wantErr: true},
+		{name: "Unknown", c: &ChartInfo{Derive: "bogus"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := deriveTransform(tt.c)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("deriveTransform() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && math.Abs(f(tt.lon)-tt.want) > 1e-9 {
+				t.Errorf("deriveTransform() = %v, want %v", f(tt.lon), tt.want)
+			}
+		})
+	}
+}
+
+func TestChartInfoHandlerDerive(t *testing.T) {
+	sweSetEphePath("swe")
+	defer sweClose()
+
+	tests := []struct {
+		name string
+		url  string
+		want []string
+	}{
+		{
+			name: "Draconic chart",
+			url:  "/chartinfo?year=2019&month=2&day=18&time=16.083334&lat=48&lon=2&display=0,1,11&derive=draconic",
+			want: []string{
+				`derive="draconic" draconic_node="TrueNode"`,
+				`<TrueNode sign_name="Aries" dist="0" degree_ut="0"`,
+				`<House sign_name="Aries"`,
+			},
+		},
+		{
+			name: "Sidereal draconic chart",
+			url:  "/chartinfo?year=2019&month=2&day=18&time=16.083334&lat=48&lon=2&display=0,1,11&ayanamsa=lahiri&derive=draconic",
+			want: []string{
+				`derive="draconic" draconic_node="TrueNode"`,
+				`<TrueNode sign_name="Aries" dist="0" degree_ut="0"`,
+			},
+		},
+		{
+			name: "Harmonic chart",
+			url:  "/chartinfo?year=2019&month=2&day=18&time=16.083334&lat=48&lon=2&display=0,1,11&derive=harmonic&harmonic=5",
+			want: []string{
+				`derive="harmonic" harmonic="5"`,
+				`<Sun sign_name="Libra" dist="0" degree_ut="208.53`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("GET", tt.url, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rr := httptest.NewRecorder()
+			handler := http.HandlerFunc(ChartInfoHandler)
+			handler.ServeHTTP(rr, req)
+
+			for _, want := range tt.want {
+				if !strings.Contains(rr.Body.String(), want) {
+					t.Errorf("handler returned wrong xml: got %v want %v", rr.Body.String(), want)
+				}
+			}
+		})
+	}
+
+	for _, url := range []string{"/chartinfo?derive=bogus", "/chartinfo?derive=harmonic&harmonic=0"} {
+		req, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(ChartInfoHandler).ServeHTTP(rr, req)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%v returned status %v, want %v", url, rr.Code, http.StatusBadRequest)
+		}
+	}
+}
@@ -0,0 +1,401 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+)
+
+// Dignity holds the essential dignities of a planet in its sign and degree
+type Dignity struct {
+	Domicile   bool   `xml:"domicile,attr"`
+	Exaltation bool   `xml:"exaltation,attr"`
+	Triplicity bool   `xml:"triplicity,attr"`
+	Term       bool   `xml:"term,attr"`
+	Face       bool   `xml:"face,attr"`
+	Detriment  bool   `xml:"detriment,attr"`
+	Fall       bool   `xml:"fall,attr"`
+	Peregrine  bool   `xml:"peregrine,attr"`
+	Score      int    `xml:"score,attr"`
+	Dispositor string `xml:"dispositor,attr"`
+}
+
+// Dignities is the optional section with the almutens of the house cusps,
+// the mutual receptions and the dispositors of a chart
+type Dignities struct {
+	Almutens         []Almuten         `xml:"almutens>Almuten"`
+	Receptions       []MutualReception `xml:"receptions>MutualReception"`
+	Dispositors      []Dispositor      `xml:"dispositors>Dispositor"`
+	FinalDispositor  string            `xml:"final_dispositor,attr,omitempty"`
+	TriplicityScheme string            `xml:"triplicity,attr"`
+	TermScheme       string            `xml:"terms,attr"`
+	Rulers           string            `xml:"rulers,attr"`
+}
+
+// Almuten is the planet with the most essential dignities on a house cusp
+type Almuten struct {
+	House  string `xml:"house,attr"`
+	Planet string `xml:"planet,attr"`
+	Score  int    `xml:"score,attr"`
+}
+
+// MutualReception happens when two planets are each in a sign of the other
+type MutualReception struct {
+	Body1 string `xml:"body1,attr"`
+	Body2 string `xml:"body2,attr"`
+	Kind  string `xml:"kind,attr"`
+}
+
+// Dispositor lists the chain of domicile rulers starting from a planet
+type Dispositor struct {
+	Body  string `xml:"body,attr"`
+	Chain string `xml:"chain,attr"`
+}
+
+// The seven traditional planets, in the order of the swisseph body numbers
+var traditionalPlanets = []string{"Sun", "Moon", "Mercury", "Venus", "Mars", "Jupiter", "Saturn"}
+
+// Domicile rulers of the signs
+var domiciles = []string{"Mars", "Venus", "Mercury", "Moon", "Sun", "Mercury",
+	"Venus", "Mars", "Jupiter", "Saturn", "Saturn", "Jupiter"}
+
+// Modern rulers of Scorpio, Aquarius and Pisces
+var modernDomiciles = []string{"Mars", "Venus", "Mercury", "Moon", "Sun", "Mercury",
+	"Venus", "Pluto", "Jupiter", "Saturn", "Uranus", "Neptune"}
+
+// Exaltation rulers of the signs, the empty ones have none
+var exaltations = []string{"Sun", "Moon", "", "Jupiter", "", "Mercury",
+	"Saturn", "", "", "Mars", "", "Venus"}
+
+// Triplicity rulers of the elements, by day, by night and participating
+var dorotheanTriplicities = [][]string{
+	{"Sun", "Jupiter", "Saturn"},
+	{"Venus", "Moon", "Mars"},
+	{"Saturn", "Mercury", "Jupiter"},
+	{"Venus", "Mars", "Moon"},
+}
+
+// Triplicity rulers of the elements used by William Lilly
+var lillyTriplicities = [][]string{
+	{"Sun", "Jupiter"},
+	{"Venus", "Moon"},
+	{"Saturn", "Mercury"},
+	{"Mars", "Mars"},
+}
+
+type term struct {
+	end    float64
+	planet string
+}
+
+// Egyptian terms of the signs
+var egyptianTerms = [][]term{
+	{{6, "Jupiter"}, {12, "Venus"}, {20, "Mercury"}, {25, "Mars"}, {30, "Saturn"}},
+	{{8, "Venus"}, {14, "Mercury"}, {22, "Jupiter"}, {27, "Saturn"}, {30, "Mars"}},
+	{{6, "Mercury"}, {12, "Jupiter"}, {17, "Venus"}, {24, "Mars"}, {30, "Saturn"}},
+	{{7, "Mars"}, {13, "Venus"}, {19, "Mercury"}, {26, "Jupiter"}, {30, "Saturn"}},
+	{{6, "Jupiter"}, {11, "Venus"}, {18, "Saturn"}, {24, "Mercury"}, {30, "Mars"}},
+	{{7, "Mercury"}, {17, "Venus"}, {21, "Jupiter"}, {28, "Mars"}, {30, "Saturn"}},
+	{{6, "Saturn"}, {14, "Mercury"}, {21, "Jupiter"}, {28, "Venus"}, {30, "Mars"}},
+	{{7, "Mars"}, {11, "Venus"}, {19, "Mercury"}, {24, "Jupiter"}, {30, "Saturn"}},
+	{{12, "Jupiter"}, {17, "Venus"}, {21, "Mercury"}, {26, "Saturn"}, {30, "Mars"}},
+	{{7, "Mercury"}, {14, "Jupiter"}, {22, "Venus"}, {26, "Saturn"}, {30, "Mars"}},
+	{{7, "Mercury"}, {13, "Venus"}, {20, "Jupiter"}, {25, "Mars"}, {30, "Saturn"}},
+	{{12, "Venus"}, {16, "Jupiter"}, {19, "Mercury"}, {28, "Mars"}, {30, "Saturn"}},
+}
+
+// Ptolemaic terms of the signs, as given by William Lilly
+var ptolemaicTerms = [][]term{
+	{{6, "Jupiter"}, {14, "Venus"}, {21, "Mercury"}, {26, "Mars"}, {30, "Saturn"}},
+	{{8, "Venus"}, {15, "Mercury"}, {22, "Jupiter"}, {26, "Saturn"}, {30, "Mars"}},
+	{{7, "Mercury"}, {14, "Jupiter"}, {21, "Venus"}, {25, "Saturn"}, {30, "Mars"}},
+	{{6, "Mars"}, {13, "Jupiter"}, {20, "Mercury"}, {27, "Venus"}, {30, "Saturn"}},
+	{{6, "Saturn"}, {13, "Mercury"}, {19, "Venus"}, {25, "Jupiter"}, {30, "Mars"}},
+	{{7, "Mercury"}, {13, "Venus"}, {18, "Jupiter"}, {24, "Saturn"}, {30, "Mars"}},
+	{{6, "Saturn"}, {11, "Venus"}, {19, "Jupiter"}, {24, "Mercury"}, {30, "Mars"}},
+	{{6, "Mars"}, {14, "Jupiter"}, {21, "Venus"}, {27, "Mercury"}, {30, "Saturn"}},
+	{{8, "Jupiter"}, {14, "Venus"}, {19, "Mercury"}, {25, "Saturn"}, {30, "Mars"}},
+	{{6, "Venus"}, {12, "Mercury"}, {19, "Jupiter"}, {25, "Mars"}, {30, "Saturn"}},
+	{{6, "Saturn"}, {12, "Mercury"}, {20, "Venus"}, {25, "Jupiter"}, {30, "Mars"}},
+	{{8, "Venus"}, {14, "Jupiter"}, {20, "Mercury"}, {26, "Mars"}, {30, "Saturn"}},
+}
+
+// Rulers of the faces, starting with the first decan of Aries
+var faces = []string{"Mars", "Sun", "Venus", "Mercury", "Moon", "Saturn", "Jupiter"}
+
+// Settings of the essential dignities
+type dignitySettings struct {
+	triplicities [][]string
+	terms        [][]term
+	domiciles    []string
+	diurnal      bool
+}
+
+// Reads the dignity settings from the query string
+func queryDignitySettings(q url.Values, d *Dignities) dignitySettings {
+	s := dignitySettings{
+		triplicities: dorotheanTriplicities,
+		terms:        egyptianTerms,
+		domiciles:    domiciles,
+	}
+	d.TriplicityScheme = "dorothean"
+	d.TermScheme = "egyptian"
+	d.Rulers = "traditional"
+
+	if q.Get("triplicity") == "lilly" {
+		s.triplicities = lillyTriplicities
+		d.TriplicityScheme = "lilly"
+	}
+	if q.Get("terms") == "ptolemaic" {
+		s.terms = ptolemaicTerms
+		d.TermScheme = "ptolemaic"
+	}
+	if q.Get("rulers") == "modern" {
+		s.domiciles = modernDomiciles
+		d.Rulers = "modern"
+	}
+
+	return s
+}
+
+// Returns the triplicity rulers of a sign to use for the sect of the chart
+func (s dignitySettings) triplicityRulers(sign int) []string {
+	rulers := s.triplicities[sign%4]
+	first := rulers[1]
+	if s.diurnal {
+		first = rulers[0]
+	}
+	if len(rulers) > 2 {
+		return []string{first, rulers[2]}
+	}
+	return []string{first}
+}
+
+// Tells whether a planet rules a sign. With the modern rulers, the
+// traditional rulers of Scorpio, Aquarius and Pisces remain co-rulers.
+func (s dignitySettings) rules(planet string, sign int) bool {
+	return s.domiciles[sign] == planet || domiciles[sign] == planet
+}
+
+// Returns the ruler of the term containing a degree of a sign
+func (s dignitySettings) termRuler(sign int, degree float64) string {
+	for _, t := range s.terms[sign] {
+		if degree < t.end {
+			return t.planet
+		}
+	}
+	return ""
+}
+
+// Returns the ruler of the face containing a degree of a sign
+func faceRuler(sign int, degree float64) string {
+	return faces[(sign*3+int(degree/10))%len(faces)]
+}
+
+// dignityOf computes the essential dignities of a planet at a longitude
+func dignityOf(planet string, degreeUt float64, s dignitySettings) *Dignity {
+	sign, degree := signOf(degreeUt)
+	d := &Dignity{
+		Domicile:   s.rules(planet, sign),
+		Exaltation: exaltations[sign] == planet,
+		Term:       s.termRuler(sign, degree) == planet,
+		Face:       faceRuler(sign, degree) == planet,
+		Detriment:  s.rules(planet, (sign+6)%12),
+		Fall:       exaltations[(sign+6)%12] == planet,
+		Dispositor: s.domiciles[sign],
+	}
+
+	for _, p := range s.triplicityRulers(sign) {
+		if p == planet {
+			d.Triplicity = true
+		}
+	}
+
+	d.Peregrine = !d.Domicile && !d.Exaltation && !d.Triplicity && !d.Term && !d.Face
+
+	// Scores of William Lilly
+	if d.Domicile {
+		d.Score += 5
+	}
+	if d.Exaltation {
+		d.Score += 4
+	}
+	if d.Triplicity {
+		d.Score += 3
+	}
+	if d.Term {
+		d.Score += 2
+	}
+	if d.Face {
+		d.Score++
+	}
+	if d.Detriment {
+		d.Score -= 5
+	}
+	if d.Fall {
+		d.Score -= 4
+	}
+	if d.Peregrine {
+		d.Score -= 5
+	}
+
+	return d
+}
+
+// almuten returns the traditional planet with the most essential dignities
+// at a longitude, and its score
+func almuten(degreeUt float64, s dignitySettings) (string, int) {
+	sign, degree := signOf(degreeUt)
+	best, bestScore := "", 0
+
+	for _, planet := range traditionalPlanets {
+		score := 0
+		if domiciles[sign] == planet {
+			score += 5
+		}
+		if exaltations[sign] == planet {
+			score += 4
+		}
+		if s.triplicityRulers(sign)[0] == planet {
+			score += 3
+		}
+		if s.termRuler(sign, degree) == planet {
+			score += 2
+		}
+		if faceRuler(sign, degree) == planet {
+			score++
+		}
+		if score > bestScore {
+			best, bestScore = planet, score
+		}
+	}
+
+	return best, bestScore
+}
+
+// Returns the names of the planets ruled by the selected rulerships
+func ruledPlanets(s dignitySettings) []string {
+	planets := append([]string{}, traditionalPlanets...)
+	if s.domiciles[7] == "Pluto" {
+		planets = append(planets, "Uranus", "Neptune", "Pluto")
+	}
+	return planets
+}
+
+// receptions finds the mutual receptions by domicile and by exaltation
+func receptions(points map[string]float64, s dignitySettings) []MutualReception {
+	var mr []MutualReception
+	planets := ruledPlanets(s)
+
+	for i, p1 := range planets {
+		sign1, _ := signOf(points[p1])
+		for _, p2 := range planets[i+1:] {
+			sign2, _ := signOf(points[p2])
+
+			dom1, dom2 := s.domiciles[sign1] == p2, s.domiciles[sign2] == p1
+			exa1, exa2 := exaltations[sign1] == p2, exaltations[sign2] == p1
+
+			kind := ""
+			switch {
+			case dom1 && dom2:
+				kind = "domicile"
+			case exa1 && exa2:
+				kind = "exaltation"
+			case (dom1 && exa2) || (exa1 && dom2):
+				kind = "mixed"
+			}
+
+			if kind != "" {
+				mr = append(mr, MutualReception{Body1: p1, Body2: p2, Kind: kind})
+			}
+		}
+	}
+
+	return mr
+}
+
+// dispositors follows the domicile rulers from each planet until a planet
+// repeats. The final dispositor is the planet in its own domicile ending
+// every chain, if there is one.
+func dispositors(points map[string]float64, s dignitySettings) ([]Dispositor, string) {
+	var ds []Dispositor
+	final := ""
+	single := true
+
+	for _, planet := range ruledPlanets(s) {
+		chain := []string{planet}
+		seen := map[string]bool{planet: true}
+		for p := planet; ; {
+			sign, _ := signOf(points[p])
+			p = s.domiciles[sign]
+			if seen[p] {
+				break
+			}
+			seen[p] = true
+			chain = append(chain, p)
+		}
+
+		last := chain[len(chain)-1]
+		sign, _ := signOf(points[last])
+		if s.domiciles[sign] != last || (final != "" && final != last) {
+			single = false
+		}
+		final = last
+
+		ds = append(ds, Dispositor{Body: planet, Chain: strings.Join(chain, ",")})
+	}
+
+	if !single {
+		final = ""
+	}
+
+	return ds, final
+}
+
+// addDignities scores the essential dignities of the planets of a chart and
+// adds the almutens, mutual receptions and dispositors
+func addDignities(c *ChartInfo, q url.Values) error {
+	d := &Dignities{}
+	s := queryDignitySettings(q, d)
+
+	diurnal, err := isDiurnal(c)
+	if err != nil {
+		return err
+	}
+	s.diurnal = diurnal
+	c.Sect = "night"
+	if diurnal {
+		c.Sect = "day"
+	}
+
+	points, err := chartPoints(c)
+	if err != nil {
+		return err
+	}
+
+	planets := ruledPlanets(s)
+	for i, body := range c.Bodies {
+		for _, p := range planets {
+			if body.XMLName.Local == p {
+				c.Bodies[i].Dignity = dignityOf(p, body.DegreeUt, s)
+			}
+		}
+	}
+
+	// The houses of a chart cast for an unknown time are not given
+	for house := 1; house < len(c.cusps) && c.UnknownTime == nil; house++ {
+		planet, score := almuten(c.cusps[house], s)
+		d.Almutens = append(d.Almutens, Almuten{House: hnames[house], Planet: planet, Score: score})
+	}
+
+	d.Receptions = receptions(points, s)
+	d.Dispositors, d.FinalDispositor = dispositors(points, s)
+
+	c.Dignities = d
+
+	return nil
+}
+
+// Tells whether the dignities of a chart are requested
+func wantsDignities(q url.Values) bool {
+	return q.Get("dignities") == "1"
+}
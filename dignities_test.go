@@ -0,0 +1,118 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_dignityOf(t *testing.T) {
+	day := dignitySettings{triplicities: dorotheanTriplicities, terms: egyptianTerms, domiciles: domiciles, diurnal: true}
+	lilly := dignitySettings{triplicities: lillyTriplicities, terms: ptolemaicTerms, domiciles: domiciles}
+	modern := dignitySettings{triplicities: dorotheanTriplicities, terms: egyptianTerms, domiciles: modernDomiciles}
+
+	tests := []struct {
+		name     string
+		planet   string
+		degreeUt float64
+		s        dignitySettings
+		want     *Dignity
+	}{
+		{
+			name: "Sun exalted in Aries", planet: "Sun", degreeUt: 19, s: day,
+			want: &Dignity{Exaltation: true, Triplicity: true, Face: true, Score: 8, Dispositor: "Mars"},
+		},
+		{
+			name: "Mars in its domicile and term", planet: "Mars", degreeUt: 22, s: day,
+			want: &Dignity{Domicile: true, Term: true, Score: 7, Dispositor: "Mars"},
+		},
+		{
+			name: "Mercury in detriment and fall", planet: "Mercury", degreeUt: 335, s: day,
+			want: &Dignity{Detriment: true, Fall: true, Peregrine: true, Score: -14, Dispositor: "Jupiter"},
+		},
+		{
+			name: "Mars ruling the water signs by night with Lilly", planet: "Mars", degreeUt: 95, s: lilly,
+			want: &Dignity{Triplicity: true, Term: true, Fall: true, Score: 1, Dispositor: "Moon"},
+		},
+		{
+			name: "Mars keeps Scorpio with the modern rulers", planet: "Mars", degreeUt: 215, s: modern,
+			want: &Dignity{Domicile: true, Triplicity: true, Term: true, Face: true, Score: 11, Dispositor: "Pluto"},
+		},
+		{
+			name: "Pluto in detriment in Taurus", planet: "Pluto", degreeUt: 40, s: modern,
+			want: &Dignity{Detriment: true, Peregrine: true, Score: -10, Dispositor: "Venus"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := dignityOf(tt.planet, tt.degreeUt, tt.s); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("dignityOf() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_almuten(t *testing.T) {
+	day := dignitySettings{triplicities: dorotheanTriplicities, terms: egyptianTerms, domiciles: domiciles, diurnal: true}
+
+	tests := []struct {
+		name       string
+		degreeUt   float64
+		wantPlanet string
+		wantScore  int
+	}{
+		{name: "Aries 19°", degreeUt: 19, wantPlanet: "Sun", wantScore: 8},
+		{name: "Leo 2°", degreeUt: 122, wantPlanet: "Sun", wantScore: 8},
+		{name: "Capricorn 27°", degreeUt: 297, wantPlanet: "Mars", wantScore: 6},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			planet, score := almuten(tt.degreeUt, day)
+			if planet != tt.wantPlanet || score != tt.wantScore {
+				t.Errorf("almuten() = %v, %v, want %v, %v", planet, score, tt.wantPlanet, tt.wantScore)
+			}
+		})
+	}
+}
+
+func Test_dispositors(t *testing.T) {
+	s := dignitySettings{triplicities: dorotheanTriplicities, terms: egyptianTerms, domiciles: domiciles}
+	points := map[string]float64{
+		"Sun": 10, "Moon": 40, "Mercury": 130, "Venus": 20, "Mars": 5, "Jupiter": 100, "Saturn": 15,
+	}
+
+	ds, final := dispositors(points, s)
+	if final != "Mars" {
+		t.Errorf("dispositors() final = %v, want Mars", final)
+	}
+	if ds[2].Chain != "Mercury,Sun,Mars" {
+		t.Errorf("dispositors() chain = %v, want Mercury,Sun,Mars", ds[2].Chain)
+	}
+}
+
+func TestChartInfoHandlerDignities(t *testing.T) {
+	sweSetEphePath("swe")
+	defer sweClose()
+
+	req, err := http.NewRequest("GET", "/chartinfo?year=2019&month=2&day=18&time=16.083334&lat=48&lon=2&dignities=1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(ChartInfoHandler)
+	handler.ServeHTTP(rr, req)
+
+	for _, want := range []string{
+		`<dignities triplicity="dorothean" terms="egyptian" rulers="traditional">`,
+		`<Almuten house="I" planet="Sun" score="8">`,
+		`<MutualReception body1="Venus" body2="Mars" kind="mixed">`,
+		`<Dispositor body="Moon" chain="Moon,Sun,Saturn">`,
+	} {
+		if !strings.Contains(rr.Body.String(), want) {
+			t.Errorf("handler returned wrong xml: got %v want %v", rr.Body.String(), want)
+		}
+	}
+}
@@ -0,0 +1,382 @@
+package main
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+/*
+#include "swephexp.h"
+*/
+import "C"
+
+// Directions is the root node of the primary directions output
+type Directions struct {
+	XMLName    xml.Name    `xml:"directions"`
+	Directions []Direction `xml:"Direction"`
+	Method     string      `xml:"method,attr"`
+	Mode       string      `xml:"mode,attr"`
+	Key        string      `xml:"key,attr"`
+	MinAge     float64     `xml:"min_age,attr"`
+	MaxAge     float64     `xml:"max_age,attr"`
+}
+
+// Direction is a promissor, or one of its aspects, brought by the primary
+// motion to a significator
+type Direction struct {
+	Promissor    string  `xml:"promissor,attr"`
+	Significator string  `xml:"significator,attr"`
+	Aspect       string  `xml:"aspect,attr"`
+	Side         string  `xml:"side,attr,omitempty"`
+	Arc          float64 `xml:"arc,attr"`
+	Age          float64 `xml:"age,attr"`
+	Date         string  `xml:"date,attr"`
+}
+
+// Degrees of right ascension per year of life of the keys
+var directionKeys = map[string]float64{
+	"ptolemy": 1,
+	"naibod":  0.98564733,
+	"cardan":  59.0/60 + 12.0/3600,
+}
+
+// The aspects of the directions
+var directionAspects = []struct {
+	name  string
+	angle float64
+}{
+	{"Conjunction", 0},
+	{"Sextile", 60},
+	{"Square", 90},
+	{"Trine", 120},
+	{"Opposition", 180},
+}
+
+// A point of the natal chart in equatorial coordinates, with its hour angle
+// and its diurnal and nocturnal semi-arcs
+type sphericalPoint struct {
+	name string
+	ra   float64
+	dec  float64
+	ha   float64
+	dsa  float64
+	nsa  float64
+}
+
+// Settings of the primary directions
+type directionSettings struct {
+	lat     float64
+	ramc    float64
+	eps     float64
+	method  string
+	mundane bool
+	key     float64
+}
+
+const deg = math.Pi / 180
+
+// Returns an angle in the range -180 to 180
+func normalize180(angle float64) float64 {
+	angle = normalize(angle)
+	if angle > 180 {
+		angle -= 360
+	}
+	return angle
+}
+
+// eclipticToEquatorial converts ecliptic coordinates to right ascension and
+// declination, see swe_cotrans
+func eclipticToEquatorial(lon, lat, eps float64) (float64, float64) {
+	xin := [3]C.double{C.double(lon), C.double(lat), 1}
+	var xout [3]C.double
+	C.swe_cotrans(&xin[0], &xout[0], C.double(-eps))
+	return float64(xout[0]), float64(xout[1])
+}
+
+// tropicalAngles computes the tropical ascendant and MC from the ARMC, as
+// the angles of a sidereal or derived chart are shifted
+func tropicalAngles(armc, lat, eps float64) (float64, float64) {
+	var cusp [13]C.double
+	var ascmc [10]C.double
+	C.swe_houses_armc(C.double(armc), C.double(lat), C.double(eps), C.int('P'), &cusp[0], &ascmc[0])
+	return float64(ascmc[0]), float64(ascmc[1])
+}
+
+// newSphericalPoint computes the hour angle, from the upper meridian and
+// positive westward, and the semi-arcs of a point
+func (s directionSettings) newSphericalPoint(name string, ra, dec float64) sphericalPoint {
+	x := math.Max(-1, math.Min(1, math.Tan(s.lat*deg)*math.Tan(dec*deg)))
+	dsa := 90 + math.Asin(x)/deg
+	return sphericalPoint{name: name, ra: ra, dec: dec, ha: normalize180(s.ramc - ra), dsa: dsa, nsa: 180 - dsa}
+}
+
+// mundanePosition maps an hour angle to the Placidian mundane position,
+// where every quadrant counts 90°: 0 on the MC, 90 on the descendant, 180 on
+// the IC and 270 on the ascendant
+func mundanePosition(ha, dsa, nsa float64) float64 {
+	switch {
+	case ha >= 0 && ha <= dsa:
+		return 90 * ha / dsa
+	case ha > dsa:
+		return 90 + 90*(ha-dsa)/nsa
+	case ha < -dsa:
+		return 180 + 90*(ha+180)/nsa
+	}
+	return normalize(270 + 90*(ha+dsa)/dsa)
+}
+
+// hourAngle is the inverse of mundanePosition
+func hourAngle(m, dsa, nsa float64) float64 {
+	m = normalize(m)
+	switch {
+	case m < 90:
+		return m / 90 * dsa
+	case m < 180:
+		return dsa + (m-90)/90*nsa
+	case m < 270:
+		return -180 + (m-180)/90*nsa
+	}
+	return -dsa + (m-270)/90*dsa
+}
+
+// regioCircle returns where the Regiomontanus circle of position of a point
+// meets the equator, as an hour angle, and tells whether the point is on the
+// half of the circle containing this intersection
+func (s directionSettings) regioCircle(p sphericalPoint) (float64, bool) {
+	ha := p.ha * deg
+	zeta := math.Atan2(math.Sin(ha), math.Cos(ha)+math.Tan(s.lat*deg)*math.Tan(p.dec*deg)) / deg
+	return zeta, math.Abs(normalize180(p.ha-zeta)) <= 90
+}
+
+// regioHourAngle returns the hour angle at which a declination is on the
+// Regiomontanus circle of position meeting the equator at zeta
+func (s directionSettings) regioHourAngle(zeta, dec float64, sameHalf bool) (float64, bool) {
+	x := math.Tan(s.lat*deg) * math.Sin(zeta*deg) * math.Tan(dec*deg)
+	if math.Abs(x) > 1 {
+		return 0, false
+	}
+	a := math.Asin(x) / deg
+	if sameHalf {
+		return zeta + a, true
+	}
+	return zeta + 180 - a, true
+}
+
+// arc returns the arc of direction bringing a promissor to the position of
+// a significator, shifted by a mundane aspect. The bool is false when the
+// promissor never reaches that position.
+func (s directionSettings) arc(p, sig sphericalPoint, aspect float64) (float64, bool) {
+	var target float64
+
+	switch s.method {
+	case "regiomontanus":
+		zeta, sameHalf := s.regioCircle(sig)
+		ha, ok := s.regioHourAngle(zeta+aspect, p.dec, sameHalf)
+		if !ok {
+			return 0, false
+		}
+		target = ha
+	default:
+		m := mundanePosition(sig.ha, sig.dsa, sig.nsa)
+		target = hourAngle(m+aspect, p.dsa, p.nsa)
+	}
+
+	return normalize(target - p.ha), true
+}
+
+// queryDirectionSettings reads the method, the mode and the key
+func queryDirectionSettings(q url.Values) (directionSettings, *Directions, error) {
+	d := &Directions{
+		Method: q.Get("method"),
+		Mode:   q.Get("mode"),
+		Key:    q.Get("key"),
+		MinAge: queryFloat(q, "min_age", 0),
+		MaxAge: queryFloat(q, "max_age", 90),
+	}
+	if d.Method == "" {
+		d.Method = "placidus"
+	}
+	if d.Mode == "" {
+		d.Mode = "zodiacal"
+	}
+	if d.Key == "" {
+		d.Key = "naibod"
+	}
+
+	s := directionSettings{method: d.Method, mundane: d.Mode == "mundane", key: directionKeys[d.Key]}
+	if d.Method != "placidus" && d.Method != "regiomontanus" {
+		return s, d, errors.New("unknown method: " + d.Method)
+	}
+	if d.Mode != "zodiacal" && d.Mode != "mundane" {
+		return s, d, errors.New("unknown mode: " + d.Mode)
+	}
+	if s.key == 0 {
+		return s, d, errors.New("unknown key: " + d.Key)
+	}
+
+	return s, d, nil
+}
+
+// primaryDirections lists the direct primary directions of the promissors
+// to the significators within an age range, sorted by arc. In zodiacal mode
+// the aspects are cast on the ecliptic, without latitude, in mundane mode
+// they are proportional parts of the semi-arcs or of the equator.
+func primaryDirections(c *ChartInfo, s directionSettings, d *Directions, promissors []int, significators []string) error {
+	nut, err := calcUT(c.julday, C.SE_ECL_NUT, 0)
+	if err != nil {
+		return err
+	}
+	s.eps = nut[0]
+	s.lat, _ = c.location()
+	s.ramc = c.ascmc[C.SE_ARMC]
+
+	lons := make(map[string]float64)
+	bodies := make(map[string]sphericalPoint)
+	addBody := func(ipl int) error {
+		xx, err := calcUT(c.julday, ipl, C.SEFLG_EQUATORIAL)
+		if err != nil {
+			return err
+		}
+		ecl, err := calcUT(c.julday, ipl, 0)
+		if err != nil {
+			return err
+		}
+		bodies[bnames[ipl]] = s.newSphericalPoint(bnames[ipl], xx[0], xx[1])
+		lons[bnames[ipl]] = ecl[0]
+		return nil
+	}
+	for _, ipl := range promissors {
+		if err := addBody(ipl); err != nil {
+			return err
+		}
+	}
+
+	asc, mc := tropicalAngles(s.ramc, s.lat, s.eps)
+	var sigs []sphericalPoint
+	for _, name := range significators {
+		switch name {
+		case "Ascendant":
+			ra, dec := eclipticToEquatorial(asc, 0, s.eps)
+			sigs = append(sigs, s.newSphericalPoint(name, ra, dec))
+		case "MC":
+			ra, dec := eclipticToEquatorial(mc, 0, s.eps)
+			sigs = append(sigs, s.newSphericalPoint(name, ra, dec))
+		default:
+			if _, ok := bodies[name]; !ok {
+				ipl := -1
+				for i, b := range bnames[:C.SE_NPLANETS] {
+					if b == name {
+						ipl = i
+					}
+				}
+				if ipl < 0 {
+					return errors.New("unknown significator: " + name)
+				}
+				if err := addBody(ipl); err != nil {
+					return err
+				}
+			}
+			sigs = append(sigs, bodies[name])
+		}
+	}
+
+	for _, ipl := range promissors {
+		name := bnames[ipl]
+		for _, sig := range sigs {
+			if sig.name == name {
+				continue
+			}
+			for _, a := range directionAspects {
+				for _, side := range []float64{1, -1} {
+					if side < 0 && (a.angle == 0 || a.angle == 180) {
+						continue
+					}
+
+					var arc float64
+					var ok bool
+					if s.mundane {
+						arc, ok = s.arc(bodies[name], sig, side*a.angle)
+					} else {
+						ra, dec := eclipticToEquatorial(lons[name]+side*a.angle, 0, s.eps)
+						arc, ok = s.arc(s.newSphericalPoint(name, ra, dec), sig, 0)
+					}
+					if !ok {
+						continue
+					}
+
+					age := arc / s.key
+					if age < d.MinAge || age > d.MaxAge {
+						continue
+					}
+
+					dir := Direction{Promissor: name, Significator: sig.name, Aspect: a.name,
+						Arc: arc, Age: age, Date: utDate(c.julday + age*tropicalYear)}
+					if a.angle != 0 && a.angle != 180 {
+						dir.Side = map[float64]string{1: "sinister", -1: "dexter"}[side]
+					}
+					d.Directions = append(d.Directions, dir)
+				}
+			}
+		}
+	}
+
+	sort.Slice(d.Directions, func(i, j int) bool {
+		return d.Directions[i].Arc < d.Directions[j].Arc
+	})
+
+	return nil
+}
+
+// DirectionsHandler lists the primary directions of a natal chart, given
+// with the natal_ prefix, between min_age and max_age. method is placidus or
+// regiomontanus, mode zodiacal or mundane and key ptolemy, naibod or cardan.
+// The promissors are the planets and the significators the angles and the
+// lights, unless given as lists of body numbers and names.
+func DirectionsHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	s, d, err := queryDirectionSettings(q)
+	if err != nil {
+		fmt.Printf("error: %v\n", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	promissors := []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}
+	if q.Get("promissors") != "" {
+		if promissors, err = planetIDs(q.Get("promissors")); err != nil {
+			fmt.Printf("error: %v\n", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	significators := []string{"Ascendant", "MC", "Sun", "Moon"}
+	if q.Get("significators") != "" {
+		significators = strings.Split(q.Get("significators"), ",")
+	}
+	c, display, err := parseChartInfo(q, "natal_")
+	if err != nil {
+		fmt.Printf("error: %v\n", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := castChart(c, display); err != nil {
+		fmt.Printf("error: %v\n", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := primaryDirections(c, s, d, promissors, significators); err != nil {
+		fmt.Printf("error: %v\n", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writeXML(w, d)
+}
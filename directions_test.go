@@ -0,0 +1,117 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func Test_mundanePosition(t *testing.T) {
+	tests := []struct {
+		name string
+		ha   float64
+		want float64
+	}{
+		{name: "MC", ha: 0, want: 0},
+		{name: "Descendant", ha: 110, want: 90},
+		{name: "IC", ha: 180, want: 180},
+		{name: "Ascendant", ha: -110, want: 270},
+		{name: "Half the diurnal semi-arc", ha: -55, want: 315},
+		{name: "Half the nocturnal semi-arc", ha: 145, want: 135},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mundanePosition(tt.ha, 110, 70); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("mundanePosition() = %v, want %v", got, tt.want)
+			}
+			if got := normalize(hourAngle(tt.want, 110, 70)); math.Abs(got-normalize(tt.ha)) > 1e-9 {
+				t.Errorf("hourAngle() = %v, want %v", got, tt.ha)
+			}
+		})
+	}
+}
+
+func Test_arc(t *testing.T) {
+	// A promissor reaches the MC after its distance in right ascension and
+	// the ascendant after its distance in oblique ascension, with both methods
+	for _, method := range []string{"placidus", "regiomontanus"} {
+		s := directionSettings{lat: 48, ramc: 100, method: method}
+		p := s.newSphericalPoint("Jupiter", 130, 10)
+
+		mc := s.newSphericalPoint("MC", 100, 20)
+		if got, ok := s.arc(p, mc, 0); !ok || math.Abs(got-30) > 1e-9 {
+			t.Errorf("%s arc() to the MC = %v, want 30", method, got)
+		}
+
+		asc := s.newSphericalPoint("Ascendant", 100+90+5, 5)
+		asc.ha = -asc.dsa
+		asc.ra = normalize(s.ramc + asc.dsa)
+		want := normalize(-p.dsa - p.ha)
+		if got, ok := s.arc(p, asc, 0); !ok || math.Abs(got-want) > 1e-9 {
+			t.Errorf("%s arc() to the ascendant = %v, want %v", method, got, want)
+		}
+	}
+}
+
+func TestDirectionsHandler(t *testing.T) {
+	sweSetEphePath("swe")
+	defer sweClose()
+
+	tests := []struct {
+		name   string
+		query  string
+		status int
+		want   []string
+	}{
+		{
+			name:   "Placidus zodiacal",
+			query:  "natal_year=1980&natal_month=5&natal_day=17&natal_time=14.5&natal_lat=48.85&natal_lon=2.35&max_age=30&significators=Ascendant,MC",
+			status: http.StatusOK,
+			want:   []string{`method="placidus" mode="zodiacal" key="naibod"`, `promissor="Moon" significator="MC" aspect="Conjunction"`},
+		},
+		{
+			name:   "Sidereal chart",
+			query:  "natal_year=1980&natal_month=5&natal_day=17&natal_time=14.5&natal_lat=48.85&natal_lon=2.35&natal_ayanamsa=lahiri&max_age=30&significators=Ascendant,MC",
+			status: http.StatusOK,
+			want:   []string{`promissor="Moon" significator="MC" aspect="Conjunction" arc="3.1355`},
+		},
+		{
+			name:   "Regiomontanus mundane",
+			query:  "natal_year=1980&natal_month=5&natal_day=17&natal_time=14.5&natal_lat=48.85&natal_lon=2.35&method=regiomontanus&mode=mundane&key=ptolemy",
+			status: http.StatusOK,
+			want:   []string{`method="regiomontanus" mode="mundane" key="ptolemy"`, `significator="Sun"`},
+		},
+		{
+			name:   "Unknown key",
+			query:  "key=simmonite",
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "Unknown promissor",
+			query:  "promissors=0,40",
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "Unknown significator",
+			query:  "significators=Vertex",
+			status: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/directions?"+tt.query, nil)
+			w := httptest.NewRecorder()
+			DirectionsHandler(w, req)
+			if w.Code != tt.status {
+				t.Fatalf("DirectionsHandler() status = %v, want %v", w.Code, tt.status)
+			}
+			for _, s := range tt.want {
+				if !strings.Contains(w.Body.String(), s) {
+					t.Errorf("DirectionsHandler() output missing %s", s)
+				}
+			}
+		})
+	}
+}
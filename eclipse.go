@@ -0,0 +1,390 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"unsafe"
+)
+
+/*
+#include "swephexp.h"
+*/
+import "C"
+
+// Eclipses is the root node of the eclipse search output
+type Eclipses struct {
+	XMLName  xml.Name  `xml:"eclipses"`
+	Eclipses []Eclipse `xml:",any"`
+	Lat      float64   `xml:"lat,attr,omitempty"`
+	Lon      float64   `xml:"lon,attr,omitempty"`
+}
+
+// Eclipse represents a solar or a lunar eclipse as seen from the whole earth
+type Eclipse struct {
+	XMLName     xml.Name
+	Type        string         `xml:"type,attr"`
+	Central     bool           `xml:"central,attr,omitempty"`
+	Max         string         `xml:"max,attr"`
+	JD          float64        `xml:"-"`
+	Begin       string         `xml:"begin,attr,omitempty"`
+	End         string         `xml:"end,attr,omitempty"`
+	TotalBegin  string         `xml:"total_begin,attr,omitempty"`
+	TotalEnd    string         `xml:"total_end,attr,omitempty"`
+	Magnitude   float64        `xml:"magnitude,attr"`
+	SarosSeries int            `xml:"saros_series,attr"`
+	SarosMember int            `xml:"saros_member,attr"`
+	SignName    string         `xml:"sign_name,attr"`
+	DegreeUt    float64        `xml:"degree_ut,attr"`
+	Degree      float64        `xml:"degree,attr"`
+	Sign        int            `xml:"sign,attr"`
+	Local       *LocalEclipse  `xml:"local,omitempty"`
+	Contacts    []NatalContact `xml:"natal>Contact,omitempty"`
+}
+
+// LocalEclipse holds the circumstances of an eclipse for a given location
+type LocalEclipse struct {
+	Visible        bool    `xml:"visible,attr"`
+	MaxVisible     bool    `xml:"max_visible,attr"`
+	Max            string  `xml:"max,attr,omitempty"`
+	FirstContact   string  `xml:"first_contact,attr,omitempty"`
+	SecondContact  string  `xml:"second_contact,attr,omitempty"`
+	ThirdContact   string  `xml:"third_contact,attr,omitempty"`
+	FourthContact  string  `xml:"fourth_contact,attr,omitempty"`
+	PenumbralBegin string  `xml:"penumbral_begin,attr,omitempty"`
+	PenumbralEnd   string  `xml:"penumbral_end,attr,omitempty"`
+	Rise           string  `xml:"rise,attr,omitempty"`
+	Set            string  `xml:"set,attr,omitempty"`
+	Magnitude      float64 `xml:"magnitude,attr,omitempty"`
+	Obscuration    float64 `xml:"obscuration,attr,omitempty"`
+	Azimuth        float64 `xml:"azimuth,attr,omitempty"`
+	Altitude       float64 `xml:"altitude,attr,omitempty"`
+}
+
+// NatalContact is an eclipse falling on a body of a natal chart
+type NatalContact struct {
+	XMLName xml.Name
+	Body    string  `xml:"body,attr"`
+	Degree  float64 `xml:"degree_ut,attr"`
+	Orb     float64 `xml:"orb,attr"`
+}
+
+// Returns the name of an eclipse type from the flags returned by swisseph
+func eclipseType(flags int) string {
+	switch {
+	case flags&C.SE_ECL_ANNULAR_TOTAL != 0:
+		return "AnnularTotal"
+	case flags&C.SE_ECL_TOTAL != 0:
+		return "Total"
+	case flags&C.SE_ECL_ANNULAR != 0:
+		return "Annular"
+	case flags&C.SE_ECL_PENUMBRAL != 0:
+		return "Penumbral"
+	}
+	return "Partial"
+}
+
+// Formats a julian day, or returns an empty string for the zero values
+// swisseph uses when a contact does not occur
+func optDate(jd C.double) string {
+	if jd == 0 {
+		return ""
+	}
+	return utDate(float64(jd))
+}
+
+// solarEclipses lists the solar eclipses happening between two julian days
+func solarEclipses(start, end float64, geopos *[3]float64) ([]Eclipse, error) {
+	var eclipses []Eclipse
+	var tret [10]C.double
+	var attr [20]C.double
+	var where [10]C.double
+	serr := make([]byte, 256)
+
+	for t := start; ; t = float64(tret[0]) + 1 {
+		// Only the global search tells the hybrid eclipses apart, where and
+		// how give the attributes at the point of greatest eclipse
+		mu.Lock()
+		flags := C.swe_sol_eclipse_when_glob(C.double(t), C.SEFLG_SWIEPH, 0, &tret[0], 0, (*C.char)(unsafe.Pointer(&serr[0])))
+		ret := flags
+		if ret >= 0 {
+			ret = C.swe_sol_eclipse_where(tret[0], C.SEFLG_SWIEPH, &where[0], &attr[0], (*C.char)(unsafe.Pointer(&serr[0])))
+		}
+		if ret >= 0 {
+			ret = C.swe_sol_eclipse_how(tret[0], C.SEFLG_SWIEPH, &where[0], &attr[0], (*C.char)(unsafe.Pointer(&serr[0])))
+		}
+		mu.Unlock()
+
+		if ret < 0 {
+			return eclipses, sweError(serr)
+		}
+		if float64(tret[0]) > end {
+			break
+		}
+
+		e := Eclipse{
+			XMLName:     xml.Name{Local: "SolarEclipse"},
+			Type:        eclipseType(int(flags)),
+			Central:     int(flags)&C.SE_ECL_CENTRAL != 0,
+			Max:         utDate(float64(tret[0])),
+			JD:          float64(tret[0]),
+			Begin:       optDate(tret[2]),
+			End:         optDate(tret[3]),
+			TotalBegin:  optDate(tret[4]),
+			TotalEnd:    optDate(tret[5]),
+			Magnitude:   float64(attr[8]),
+			SarosSeries: int(attr[9]),
+			SarosMember: int(attr[10]),
+		}
+
+		xx, err := calcUT(e.JD, C.SE_SUN, 0)
+		if err != nil {
+			return eclipses, err
+		}
+		e.DegreeUt = xx[0]
+		e.Sign, e.Degree = signOf(e.DegreeUt)
+		e.SignName = snames[e.Sign]
+
+		if geopos != nil {
+			if e.Local, err = localSolarEclipse(e.JD, geopos); err != nil {
+				return eclipses, err
+			}
+		}
+
+		eclipses = append(eclipses, e)
+	}
+
+	return eclipses, nil
+}
+
+// localSolarEclipse returns the local circumstances of the solar eclipse
+// whose global maximum is at jd
+func localSolarEclipse(jd float64, geopos *[3]float64) (*LocalEclipse, error) {
+	var tret [10]C.double
+	var attr [20]C.double
+	serr := make([]byte, 256)
+
+	mu.Lock()
+	ret := C.swe_sol_eclipse_when_loc(C.double(jd-1), C.SEFLG_SWIEPH, (*C.double)(unsafe.Pointer(&geopos[0])), &tret[0], &attr[0], 0, (*C.char)(unsafe.Pointer(&serr[0])))
+	mu.Unlock()
+
+	if ret < 0 {
+		return nil, sweError(serr)
+	}
+
+	// The search skips eclipses that can't be seen from this location
+	if math.Abs(float64(tret[0])-jd) > 1 {
+		return &LocalEclipse{}, nil
+	}
+
+	return &LocalEclipse{
+		Visible:       int(ret)&C.SE_ECL_VISIBLE != 0,
+		MaxVisible:    int(ret)&C.SE_ECL_MAX_VISIBLE != 0,
+		Max:           optDate(tret[0]),
+		FirstContact:  optDate(tret[1]),
+		SecondContact: optDate(tret[2]),
+		ThirdContact:  optDate(tret[3]),
+		FourthContact: optDate(tret[4]),
+		Rise:          optDate(tret[5]),
+		Set:           optDate(tret[6]),
+		Magnitude:     float64(attr[8]),
+		Obscuration:   float64(attr[2]),
+		Azimuth:       float64(attr[4]),
+		Altitude:      float64(attr[6]),
+	}, nil
+}
+
+// lunarEclipses lists the lunar eclipses happening between two julian days
+func lunarEclipses(start, end float64, geopos *[3]float64) ([]Eclipse, error) {
+	var eclipses []Eclipse
+	var tret [10]C.double
+	var attr [20]C.double
+	var center [3]C.double
+	serr := make([]byte, 256)
+
+	for t := start; ; t = float64(tret[0]) + 1 {
+		mu.Lock()
+		ret := C.swe_lun_eclipse_when(C.double(t), C.SEFLG_SWIEPH, 0, &tret[0], 0, (*C.char)(unsafe.Pointer(&serr[0])))
+		if ret >= 0 {
+			C.swe_lun_eclipse_how(tret[0], C.SEFLG_SWIEPH, &center[0], &attr[0], (*C.char)(unsafe.Pointer(&serr[0])))
+		}
+		mu.Unlock()
+
+		if ret < 0 {
+			return eclipses, sweError(serr)
+		}
+		if float64(tret[0]) > end {
+			break
+		}
+
+		e := Eclipse{
+			XMLName:     xml.Name{Local: "LunarEclipse"},
+			Type:        eclipseType(int(ret)),
+			Max:         utDate(float64(tret[0])),
+			JD:          float64(tret[0]),
+			Begin:       optDate(tret[6]),
+			End:         optDate(tret[7]),
+			TotalBegin:  optDate(tret[4]),
+			TotalEnd:    optDate(tret[5]),
+			Magnitude:   float64(attr[0]),
+			SarosSeries: int(attr[9]),
+			SarosMember: int(attr[10]),
+		}
+
+		// Penumbral eclipses have no umbral magnitude
+		if int(ret)&C.SE_ECL_PENUMBRAL != 0 {
+			e.Magnitude = float64(attr[1])
+		}
+
+		xx, err := calcUT(e.JD, C.SE_MOON, 0)
+		if err != nil {
+			return eclipses, err
+		}
+		e.DegreeUt = xx[0]
+		e.Sign, e.Degree = signOf(e.DegreeUt)
+		e.SignName = snames[e.Sign]
+
+		if geopos != nil {
+			if e.Local, err = localLunarEclipse(e.JD, geopos); err != nil {
+				return eclipses, err
+			}
+		}
+
+		eclipses = append(eclipses, e)
+	}
+
+	return eclipses, nil
+}
+
+// localLunarEclipse returns the local circumstances of the lunar eclipse
+// whose maximum is at jd
+func localLunarEclipse(jd float64, geopos *[3]float64) (*LocalEclipse, error) {
+	var tret [10]C.double
+	var attr [20]C.double
+	serr := make([]byte, 256)
+
+	mu.Lock()
+	ret := C.swe_lun_eclipse_when_loc(C.double(jd-1), C.SEFLG_SWIEPH, (*C.double)(unsafe.Pointer(&geopos[0])), &tret[0], &attr[0], 0, (*C.char)(unsafe.Pointer(&serr[0])))
+	mu.Unlock()
+
+	if ret < 0 {
+		return nil, sweError(serr)
+	}
+
+	if math.Abs(float64(tret[0])-jd) > 1 {
+		return &LocalEclipse{}, nil
+	}
+
+	return &LocalEclipse{
+		Visible:        int(ret)&C.SE_ECL_VISIBLE != 0,
+		MaxVisible:     int(ret)&C.SE_ECL_MAX_VISIBLE != 0,
+		Max:            optDate(tret[0]),
+		FirstContact:   optDate(tret[2]),
+		SecondContact:  optDate(tret[4]),
+		ThirdContact:   optDate(tret[5]),
+		FourthContact:  optDate(tret[3]),
+		PenumbralBegin: optDate(tret[6]),
+		PenumbralEnd:   optDate(tret[7]),
+		Rise:           optDate(tret[8]),
+		Set:            optDate(tret[9]),
+		Magnitude:      float64(attr[0]),
+		Azimuth:        float64(attr[4]),
+		Altitude:       float64(attr[6]),
+	}, nil
+}
+
+// natalContacts finds the natal bodies conjunct or opposed to an eclipse
+func natalContacts(e Eclipse, natal *ChartInfo, orb float64) []NatalContact {
+	var contacts []NatalContact
+
+	for _, body := range natal.Bodies {
+		d := angleDiff(e.DegreeUt, body.DegreeUt)
+		if d <= orb {
+			contacts = append(contacts, NatalContact{
+				XMLName: xml.Name{Local: "Conjunction"},
+				Body:    body.XMLName.Local,
+				Degree:  body.DegreeUt,
+				Orb:     d,
+			})
+		} else if 180-d <= orb {
+			contacts = append(contacts, NatalContact{
+				XMLName: xml.Name{Local: "Opposition"},
+				Body:    body.XMLName.Local,
+				Degree:  body.DegreeUt,
+				Orb:     180 - d,
+			})
+		}
+	}
+
+	return contacts
+}
+
+// queryGeopos reads an observer position from the query string, or returns
+// nil if no location is given
+func queryGeopos(q url.Values) *[3]float64 {
+	if q.Get("lat") == "" && q.Get("lon") == "" {
+		return nil
+	}
+
+	return &[3]float64{queryFloat(q, "lon", 0), queryFloat(q, "lat", 0), queryFloat(q, "alt", 0)}
+}
+
+// EclipsesHandler lists the solar and lunar eclipses of a time range
+func EclipsesHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	start, end := queryRange(q)
+	geopos := queryGeopos(q)
+
+	var e = &Eclipses{}
+	if geopos != nil {
+		e.Lon, e.Lat = geopos[0], geopos[1]
+	}
+
+	if q.Get("kind") != "lunar" {
+		solar, err := solarEclipses(start, end, geopos)
+		if err != nil {
+			fmt.Printf("error: %v\n", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		e.Eclipses = append(e.Eclipses, solar...)
+	}
+
+	if q.Get("kind") != "solar" {
+		lunar, err := lunarEclipses(start, end, geopos)
+		if err != nil {
+			fmt.Printf("error: %v\n", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		e.Eclipses = append(e.Eclipses, lunar...)
+	}
+
+	sort.Slice(e.Eclipses, func(i, j int) bool {
+		return e.Eclipses[i].JD < e.Eclipses[j].JD
+	})
+
+	if q.Get("natal_year") != "" {
+		natal, display, err := parseChartInfo(q, "natal_")
+		if err != nil {
+			fmt.Printf("error: %v\n", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := castChart(natal, display); err != nil {
+			fmt.Printf("error: %v\n", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		orb := queryFloat(q, "orb", 3)
+		for i := range e.Eclipses {
+			e.Eclipses[i].Contacts = natalContacts(e.Eclipses[i], natal, orb)
+		}
+	}
+
+	writeXML(w, e)
+}
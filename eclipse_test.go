@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestEclipsesHandler(t *testing.T) {
+	sweSetEphePath("swe")
+	defer sweClose()
+
+	req, err := http.NewRequest("GET", "/eclipses?year=2019&month=1&day=1&lat=-30&lon=-70&natal_year=1980&natal_month=7&natal_day=3&natal_time=12", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(EclipsesHandler)
+
+	handler.ServeHTTP(rr, req)
+
+	var got Eclipses
+	if err := xml.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got.Eclipses) != 5 {
+		t.Fatalf("handler returned %v eclipses, want 5", len(got.Eclipses))
+	}
+
+	total := got.Eclipses[2]
+	if total.Type != "Total" || total.SarosSeries != 127 || total.SignName != "Cancer" {
+		t.Errorf("handler returned wrong eclipse: got %v %v %v want Total 127 Cancer",
+			total.Type, total.SarosSeries, total.SignName)
+	}
+
+	if total.Local == nil || !total.Local.Visible {
+		t.Errorf("handler returned eclipse not visible from Chile")
+	}
+
+	if !strings.Contains(rr.Body.String(), `<Conjunction body="Sun"`) {
+		t.Errorf("handler returned no eclipse on the natal Sun")
+	}
+}
+
+func TestEclipsesHandlerHybrid(t *testing.T) {
+	sweSetEphePath("swe")
+	defer sweClose()
+
+	req, err := http.NewRequest("GET", "/eclipses?year=2023&month=4&day=1&kind=solar", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(EclipsesHandler).ServeHTTP(rr, req)
+
+	var got Eclipses
+	if err := xml.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+
+	// The eclipse of 2023-04-20 is annular then total along its path
+	if len(got.Eclipses) == 0 || !strings.HasPrefix(got.Eclipses[0].Max, "2023-04-20") {
+		t.Fatalf("handler returned %+v, want the eclipse of 2023-04-20 first", got.Eclipses)
+	}
+	if e := got.Eclipses[0]; e.Type != "AnnularTotal" || !e.Central {
+		t.Errorf("handler returned a %v eclipse, central %v, want a central AnnularTotal eclipse", e.Type, e.Central)
+	}
+}
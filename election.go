@@ -0,0 +1,499 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+/*
+#include "swephexp.h"
+*/
+import "C"
+
+// Elections is the root node of the election finder output
+type Elections struct {
+	XMLName  xml.Name         `xml:"elections"`
+	Windows  []ElectionWindow `xml:"Window"`
+	RuleSet  string           `xml:"rules,attr,omitempty"`
+	Start    string           `xml:"start,attr"`
+	End      string           `xml:"end,attr"`
+	Step     float64          `xml:"step,attr"`
+	Lat      float64          `xml:"lat,attr"`
+	Lon      float64          `xml:"lon,attr"`
+	MaxScore float64          `xml:"max_score,attr"`
+}
+
+// ElectionWindow is a span of time over which the same rules hold, with
+// the result of each rule
+type ElectionWindow struct {
+	Start   string           `xml:"start,attr"`
+	End     string           `xml:"end,attr"`
+	Score   float64          `xml:"score,attr"`
+	Results []ElectionResult `xml:"Rule"`
+}
+
+// ElectionResult is the outcome of a rule for a window
+type ElectionResult struct {
+	Rule   string  `xml:"rule,attr"`
+	Name   string  `xml:"name,attr,omitempty"`
+	Passed bool    `xml:"passed,attr"`
+	Weight float64 `xml:"weight,attr"`
+	Detail string  `xml:"detail,attr,omitempty"`
+}
+
+// electionRuleSet is a named list of rules, as read from JSON
+type electionRuleSet struct {
+	Name  string         `json:"name"`
+	Rules []electionRule `json:"rules"`
+}
+
+// electionRule is a condition on the chart of a moment. The rules about
+// bodies hold when any of the bodies satisfies them, and not negates the
+// rule, so that "no malefic on the MC" is written as
+//
+//	{"rule": "on_angle", "bodies": ["malefics"], "angle": "MC", "not": true}
+type electionRule struct {
+	Rule   string   `json:"rule"`
+	Name   string   `json:"name,omitempty"`
+	Bodies []string `json:"bodies,omitempty"`
+	To     []string `json:"to,omitempty"`
+	Aspect string   `json:"aspect,omitempty"`
+	Angle  string   `json:"angle,omitempty"`
+	House  int      `json:"house,omitempty"`
+	Signs  []string `json:"signs,omitempty"`
+	Orb    float64  `json:"orb,omitempty"`
+	Not    bool     `json:"not,omitempty"`
+	Weight float64  `json:"weight,omitempty"`
+}
+
+// Names standing for several bodies in the rules
+var bodyGroups = map[string][]string{
+	"benefics": {"Venus", "Jupiter"},
+	"malefics": {"Mars", "Saturn"},
+}
+
+// Angles of the chart by name, as offsets from the ascendant or the MC
+var electionAngles = map[string]struct {
+	mc     bool
+	offset float64
+}{
+	"ASC": {false, 0}, "Ascendant": {false, 0},
+	"DSC": {false, 180}, "Descendant": {false, 180},
+	"MC": {true, 0},
+	"IC": {true, 180},
+}
+
+// Rule sets shipped with the election finder
+var electionPresets = map[string]string{
+	"general": `{"name": "general", "rules": [
+		{"rule": "moon_waxing"},
+		{"rule": "void_of_course", "not": true, "weight": 2},
+		{"rule": "retrograde", "bodies": ["Venus"], "not": true},
+		{"rule": "on_angle", "name": "benefic on ASC", "bodies": ["benefics"], "angle": "ASC"},
+		{"rule": "on_angle", "name": "no malefic on MC", "bodies": ["malefics"], "angle": "MC", "not": true}
+	]}`,
+	"contract": `{"name": "contract", "rules": [
+		{"rule": "retrograde", "bodies": ["Mercury"], "not": true, "weight": 2},
+		{"rule": "void_of_course", "not": true, "weight": 2},
+		{"rule": "moon_waxing"},
+		{"rule": "aspect", "bodies": ["Moon"], "to": ["Mercury", "benefics"], "aspect": "Trine"},
+		{"rule": "in_house", "bodies": ["malefics"], "house": 7, "not": true}
+	]}`,
+	"marriage": `{"name": "marriage", "rules": [
+		{"rule": "retrograde", "bodies": ["Venus"], "not": true, "weight": 2},
+		{"rule": "void_of_course", "not": true, "weight": 2},
+		{"rule": "moon_waxing"},
+		{"rule": "aspect", "bodies": ["Moon"], "to": ["Venus"], "aspect": "Conjunction"},
+		{"rule": "in_house", "bodies": ["benefics"], "house": 7},
+		{"rule": "on_angle", "bodies": ["malefics"], "angle": "DSC", "not": true}
+	]}`,
+}
+
+// Default orb of a body on an angle, in degrees
+const angleOrb = 5
+
+// bodyID returns the number of a body by name
+func bodyID(name string) (int, bool) {
+	for id, n := range bnames {
+		if n == name {
+			return id, true
+		}
+	}
+	return 0, false
+}
+
+// expandBodies replaces the groups of bodies by their members and checks
+// the names of the bodies
+func expandBodies(names []string) ([]string, error) {
+	var bodies []string
+	for _, name := range names {
+		if group, ok := bodyGroups[name]; ok {
+			bodies = append(bodies, group...)
+			continue
+		}
+		if _, ok := bodyID(name); !ok {
+			return nil, errors.New("unknown body: " + name)
+		}
+		bodies = append(bodies, name)
+	}
+	return bodies, nil
+}
+
+// parseElectionRules reads a rule set from JSON and checks its rules
+func parseElectionRules(data []byte) (*electionRuleSet, error) {
+	rs := &electionRuleSet{}
+	if err := json.Unmarshal(data, rs); err != nil {
+		return nil, err
+	}
+	if len(rs.Rules) == 0 {
+		return nil, errors.New("the rule set has no rules")
+	}
+
+	for i := range rs.Rules {
+		r := &rs.Rules[i]
+		var err error
+		if r.Bodies, err = expandBodies(r.Bodies); err != nil {
+			return nil, err
+		}
+		if r.To, err = expandBodies(r.To); err != nil {
+			return nil, err
+		}
+		if r.Weight == 0 {
+			r.Weight = 1
+		}
+
+		switch r.Rule {
+		case "moon_waxing", "void_of_course":
+		case "retrograde":
+			if len(r.Bodies) == 0 {
+				return nil, errors.New("retrograde rule without bodies")
+			}
+		case "on_angle":
+			if _, ok := electionAngles[r.Angle]; !ok || len(r.Bodies) == 0 {
+				return nil, fmt.Errorf("on_angle rule needs bodies and an angle, got %v", r.Angle)
+			}
+			if r.Orb == 0 {
+				r.Orb = angleOrb
+			}
+		case "in_house":
+			if r.House < 1 || r.House > 12 || len(r.Bodies) == 0 {
+				return nil, fmt.Errorf("in_house rule needs bodies and a house, got %v", r.House)
+			}
+		case "in_sign":
+			if len(r.Bodies) == 0 || len(r.Signs) == 0 {
+				return nil, errors.New("in_sign rule without bodies or signs")
+			}
+			for _, s := range r.Signs {
+				if signIndex(s) < 0 {
+					return nil, errors.New("unknown sign: " + s)
+				}
+			}
+		case "aspect":
+			a, ok := aspectByName(r.Aspect)
+			if !ok || len(r.Bodies) == 0 || len(r.To) == 0 {
+				return nil, fmt.Errorf("aspect rule needs bodies, to and an aspect, got %v", r.Aspect)
+			}
+			if r.Orb == 0 {
+				r.Orb = a.orb
+			}
+		default:
+			return nil, errors.New("unknown rule: " + r.Rule)
+		}
+	}
+
+	return rs, nil
+}
+
+// signIndex returns the number of a sign by name, or -1
+func signIndex(name string) int {
+	for i, s := range snames {
+		if strings.EqualFold(s, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// electionMoment is the chart of a moment being judged, with the void of
+// course period of the Moon kept from one moment to the next
+type electionMoment struct {
+	c     *ChartInfo
+	lons  map[string]float64
+	speed map[string]float64
+	void  *VoidPeriod
+}
+
+// position returns the longitude and the speed of a body at the moment,
+// computed once
+func (m *electionMoment) position(name string) (float64, float64, error) {
+	if lon, ok := m.lons[name]; ok {
+		return lon, m.speed[name], nil
+	}
+	id, _ := bodyID(name)
+	xx, err := m.c.bodyPosition(m.c.julday, id, C.SEFLG_SPEED)
+	if err != nil {
+		return 0, 0, err
+	}
+	m.lons[name], m.speed[name] = xx[0], xx[3]
+	return xx[0], xx[3], nil
+}
+
+// anyBody tells if any of the bodies satisfies a test, and which ones do
+func (m *electionMoment) anyBody(bodies []string, test func(name string, lon, speed float64) bool) (bool, string, error) {
+	var found []string
+	for _, name := range bodies {
+		lon, speed, err := m.position(name)
+		if err != nil {
+			return false, "", err
+		}
+		if test(name, lon, speed) {
+			found = append(found, name)
+		}
+	}
+	return len(found) > 0, strings.Join(found, ","), nil
+}
+
+// evaluate checks a rule on the chart of the moment
+func (m *electionMoment) evaluate(r electionRule) (bool, string, error) {
+	c := m.c
+	switch r.Rule {
+	case "moon_waxing":
+		sun, _, err := m.position("Sun")
+		if err != nil {
+			return false, "", err
+		}
+		moon, _, err := m.position("Moon")
+		if err != nil {
+			return false, "", err
+		}
+		return normalize(moon-sun) < 180, "", nil
+
+	case "void_of_course":
+		if m.void == nil || c.julday >= m.void.EndJD {
+			p, err := moonVoidPeriod(c.julday, defaultVoidSettings())
+			if err != nil {
+				return false, "", err
+			}
+			m.void = &p
+		}
+		if c.julday >= m.void.StartJD {
+			return true, "until " + m.void.End, nil
+		}
+		return false, "", nil
+
+	case "retrograde":
+		return m.anyBody(r.Bodies, func(name string, lon, speed float64) bool {
+			return speed < 0
+		})
+
+	case "on_angle":
+		a := electionAngles[r.Angle]
+		angle := c.ascmc[0]
+		if a.mc {
+			angle = c.ascmc[1]
+		}
+		angle = normalize(angle + a.offset)
+		return m.anyBody(r.Bodies, func(name string, lon, speed float64) bool {
+			return angleDiff(lon, angle) <= r.Orb
+		})
+
+	case "in_house":
+		return m.anyBody(r.Bodies, func(name string, lon, speed float64) bool {
+			return houseOf(c, lon) == r.House
+		})
+
+	case "in_sign":
+		return m.anyBody(r.Bodies, func(name string, lon, speed float64) bool {
+			sign, _ := signOf(lon)
+			for _, s := range r.Signs {
+				if signIndex(s) == sign {
+					return true
+				}
+			}
+			return false
+		})
+
+	case "aspect":
+		a, _ := aspectByName(r.Aspect)
+		var found []string
+		for _, name := range r.Bodies {
+			lon, _, err := m.position(name)
+			if err != nil {
+				return false, "", err
+			}
+			ok, other, err := m.anyBody(r.To, func(to string, toLon, speed float64) bool {
+				return to != name && math.Abs(angleDiff(lon, toLon)-a.delta) <= r.Orb
+			})
+			if err != nil {
+				return false, "", err
+			}
+			if ok {
+				found = append(found, name+" "+a.title+" "+other)
+			}
+		}
+		return len(found) > 0, strings.Join(found, ","), nil
+	}
+
+	return false, "", errors.New("unknown rule: " + r.Rule)
+}
+
+// judgeMoment scores the chart of a moment against a rule set
+func judgeMoment(m *electionMoment, rs *electionRuleSet) ([]ElectionResult, float64, error) {
+	var results []ElectionResult
+	score := 0.0
+	for _, r := range rs.Rules {
+		passed, detail, err := m.evaluate(r)
+		if err != nil {
+			return nil, 0, err
+		}
+		if r.Not {
+			passed = !passed
+		}
+		if passed {
+			score += r.Weight
+		}
+		results = append(results, ElectionResult{Rule: r.Rule, Name: r.Name, Passed: passed,
+			Weight: r.Weight, Detail: detail})
+	}
+	return results, score, nil
+}
+
+// sameResults tells if two moments pass and fail the same rules
+func sameResults(a, b []ElectionResult) bool {
+	for i := range a {
+		if a[i].Passed != b[i].Passed {
+			return false
+		}
+	}
+	return true
+}
+
+// The most charts an election scan may cast, a leap year every hour
+const maxElectionSteps = 366*24 + 1
+
+// elections scans a time range every step minutes from a location, and
+// merges the consecutive moments passing the same rules into windows,
+// ranked by score. A window lasts until the next moment scanned.
+func elections(base *ChartInfo, rs *electionRuleSet, start, end, step float64) ([]ElectionWindow, error) {
+	var windows []ElectionWindow
+	m := &electionMoment{}
+	for i := 0; start+float64(i)*step/1440 <= end; i++ {
+		jd := start + float64(i)*step/1440
+		year, month, day, hour := revjul(jd, C.SE_GREG_CAL)
+		c := &ChartInfo{Year: int64(year), Month: int64(month), Day: int64(day), Time: hour,
+			Lat: base.Lat, Lon: base.Lon, Hsys: base.Hsys}
+		if err := castChart(c, []int{}); err != nil {
+			return nil, err
+		}
+		m.c, m.lons, m.speed = c, make(map[string]float64), make(map[string]float64)
+
+		results, score, err := judgeMoment(m, rs)
+		if err != nil {
+			return nil, err
+		}
+
+		next := utDate(math.Min(jd+step/1440, end))
+		if n := len(windows); n > 0 && sameResults(windows[n-1].Results, results) {
+			windows[n-1].End = next
+			continue
+		}
+		windows = append(windows, ElectionWindow{Start: utDate(jd), End: next, Score: score, Results: results})
+	}
+
+	sort.SliceStable(windows, func(i, j int) bool {
+		return windows[i].Score > windows[j].Score
+	})
+
+	return windows, nil
+}
+
+// ElectionsHandler finds the best times in a time range for a location, by
+// scoring the charts cast every step minutes against a rule set. The rule
+// set is given as JSON in rules or in the body of a POST request, or by the
+// name of a preset. The best windows are returned, up to limit. A scan casts
+// at most maxElectionSteps charts.
+func ElectionsHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	start, end := queryRange(q)
+
+	data := []byte(q.Get("rules"))
+	if r.Method == http.MethodPost {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			fmt.Printf("error: %v\n", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		data = body
+	}
+	if len(data) == 0 {
+		preset := q.Get("preset")
+		if preset == "" {
+			preset = "general"
+		}
+		if electionPresets[preset] == "" {
+			err := errors.New("unknown preset: " + preset)
+			fmt.Printf("error: %v\n", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		data = []byte(electionPresets[preset])
+	}
+
+	rs, err := parseElectionRules(data)
+	if err != nil {
+		fmt.Printf("error: %v\n", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	base, _, err := parseChartInfo(q, "")
+	if err != nil {
+		fmt.Printf("error: %v\n", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	step := queryFloat(q, "step", 60)
+	if step <= 0 {
+		step = 60
+	}
+	if end <= start {
+		err := errors.New("the end of the range must be after its start")
+		fmt.Printf("error: %v\n", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if (end-start)*1440/step >= maxElectionSteps {
+		err := fmt.Errorf("too many steps, at most %d", maxElectionSteps)
+		fmt.Printf("error: %v\n", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	windows, err := elections(base, rs, start, end, step)
+	if err != nil {
+		fmt.Printf("error: %v\n", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	limit := int(queryInt(q, "limit", 10))
+	if limit > 0 && limit < len(windows) {
+		windows = windows[:limit]
+	}
+
+	e := &Elections{Windows: windows, RuleSet: rs.Name, Start: utDate(start), End: utDate(end),
+		Step: step, Lat: base.Lat, Lon: base.Lon}
+	for _, r := range rs.Rules {
+		e.MaxScore += r.Weight
+	}
+
+	writeXML(w, e)
+}
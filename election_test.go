@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func Test_parseElectionRules(t *testing.T) {
+	for name, preset := range electionPresets {
+		if _, err := parseElectionRules([]byte(preset)); err != nil {
+			t.Errorf("preset %v: %v", name, err)
+		}
+	}
+
+	tests := []struct {
+		rules   string
+		wantErr bool
+	}{
+		{`{"rules": [{"rule": "moon_waxing"}]}`, false},
+		{`{"rules": [{"rule": "in_sign", "bodies": ["Moon"], "signs": ["taurus", "Cancer"]}]}`, false},
+		{`{"rules": []}`, true},
+		{`{"rules": [{"rule": "full_moon"}]}`, true},
+		{`{"rules": [{"rule": "retrograde"}]}`, true},
+		{`{"rules": [{"rule": "retrograde", "bodies": ["Vulcan"]}]}`, true},
+		{`{"rules": [{"rule": "on_angle", "bodies": ["Venus"], "angle": "Vertex"}]}`, true},
+		{`{"rules": [{"rule": "in_house", "bodies": ["Venus"], "house": 13}]}`, true},
+		{`{"rules": [{"rule": "in_sign", "bodies": ["Moon"], "signs": ["Ophiuchus"]}]}`, true},
+		{`{"rules": [{"rule": "aspect", "bodies": ["Moon"], "to": ["Venus"], "aspect": "Quintile"}]}`, true},
+		{`{"rules": [`, true},
+	}
+	for _, tt := range tests {
+		_, err := parseElectionRules([]byte(tt.rules))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseElectionRules(%v) error = %v, wantErr %v", tt.rules, err, tt.wantErr)
+		}
+	}
+
+	rs, _ := parseElectionRules([]byte(`{"rules": [{"rule": "on_angle", "bodies": ["benefics", "Sun"], "angle": "MC"}]}`))
+	r := rs.Rules[0]
+	if strings.Join(r.Bodies, ",") != "Venus,Jupiter,Sun" || r.Orb != angleOrb || r.Weight != 1 {
+		t.Errorf("parseElectionRules() = %v %v %v, want Venus,Jupiter,Sun %v 1", r.Bodies, r.Orb, r.Weight, angleOrb)
+	}
+}
+
+func Test_judgeMoment(t *testing.T) {
+	sweSetEphePath("swe")
+	defer sweClose()
+
+	rs, err := parseElectionRules([]byte(`{"rules": [
+		{"rule": "retrograde", "bodies": ["Mercury", "Venus"]},
+		{"rule": "moon_waxing", "weight": 2},
+		{"rule": "void_of_course"},
+		{"rule": "in_sign", "bodies": ["Sun", "Moon"], "signs": ["Aries"]},
+		{"rule": "aspect", "bodies": ["Sun"], "to": ["Moon"], "aspect": "Conjunction"},
+		{"rule": "in_house", "bodies": ["Sun"], "house": 10, "weight": 3}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Solar eclipse of April 8, 2024, at maximum over Mexico
+	c := &ChartInfo{Year: 2024, Month: 4, Day: 8, Time: 18.3, Lat: 25.3, Lon: -104.1, Hsys: "P"}
+	if err := castChart(c, []int{}); err != nil {
+		t.Fatal(err)
+	}
+	m := &electionMoment{c: c, lons: make(map[string]float64), speed: make(map[string]float64)}
+
+	results, score, err := judgeMoment(m, rs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		passed bool
+		detail string
+	}{
+		{true, "Mercury"},
+		{false, ""},
+		{false, ""},
+		{true, "Sun,Moon"},
+		{true, "Sun Conjunction Moon"},
+		{true, "Sun"},
+	}
+	for i, w := range want {
+		if results[i].Passed != w.passed || results[i].Detail != w.detail {
+			t.Errorf("rule %v = %v %q, want %v %q", results[i].Rule, results[i].Passed, results[i].Detail, w.passed, w.detail)
+		}
+	}
+	if score != 6 {
+		t.Errorf("judgeMoment() score = %v, want 6", score)
+	}
+}
+
+func TestElectionsHandler(t *testing.T) {
+	sweSetEphePath("swe")
+	defer sweClose()
+
+	req, err := http.NewRequest("GET", "/elections?year=2024&month=3&day=1&end_year=2024&end_month=3&end_day=15&lat=48.85&lon=2.35&limit=5", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(ElectionsHandler)
+
+	handler.ServeHTTP(rr, req)
+
+	var got Elections
+	if err := xml.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+
+	if got.RuleSet != "general" || got.MaxScore != 6 || len(got.Windows) != 5 {
+		t.Fatalf("handler returned %v %v with %v windows, want general 6 with 5", got.RuleSet, got.MaxScore, len(got.Windows))
+	}
+	for i, w := range got.Windows {
+		if len(w.Results) != 5 {
+			t.Errorf("window %v has %v results, want 5", i, len(w.Results))
+		}
+		if i > 0 && w.Score > got.Windows[i-1].Score {
+			t.Errorf("window %v scores %v, more than the previous one", i, w.Score)
+		}
+		if w.End <= w.Start {
+			t.Errorf("window %v ends at %v before it starts at %v", i, w.End, w.Start)
+		}
+	}
+
+	body := `{"rules": [{"rule": "retrograde", "bodies": ["Mercury"]}]}`
+	req, err = http.NewRequest("POST", "/elections?year=2024&month=4&day=1&end_year=2024&end_month=4&end_day=3&lat=48.85&lon=2.35", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	got = Elections{}
+	if err := xml.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Windows) != 2 || got.Windows[0].Start != "2024-04-01T23:00:00Z" || got.Windows[0].End != "2024-04-03T00:00:00Z" {
+		t.Errorf("handler returned wrong windows for Mercury retrograde: %+v", got.Windows)
+	}
+
+	for _, url := range []string{"/elections?preset=funeral", "/elections?rules=%7B%7D",
+		"/elections?year=2020&end_year=2019", "/elections?year=2020&end_year=2021&step=1"} {
+		req, err = http.NewRequest("GET", url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr = httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%v returned status %v, want %v", url, rr.Code, http.StatusBadRequest)
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"unsafe"
+)
+
+/*
+#include "swephexp.h"
+*/
+import "C"
+
+// Heliacal is the root node of the heliacal events output
+type Heliacal struct {
+	XMLName  xml.Name        `xml:"heliacal"`
+	Events   []HeliacalEvent `xml:",any"`
+	Object   string          `xml:"object,attr"`
+	Lat      float64         `xml:"lat,attr"`
+	Lon      float64         `xml:"lon,attr"`
+	Alt      float64         `xml:"alt,attr"`
+	Calendar string          `xml:"calendar,attr"`
+}
+
+// HeliacalEvent is a first or last visibility of a planet or a star. Start
+// and end delimit the period during which the event can be observed.
+type HeliacalEvent struct {
+	XMLName xml.Name
+	Start   string  `xml:"start,attr"`
+	Optimum string  `xml:"optimum,attr,omitempty"`
+	End     string  `xml:"end,attr,omitempty"`
+	JD      float64 `xml:"-"`
+}
+
+// Heliacal event types, in the order of SE_HELIACAL_RISING and following
+var heliacalEvents = []struct {
+	name      string
+	eventType int
+}{
+	{"HeliacalRising", C.SE_HELIACAL_RISING},
+	{"HeliacalSetting", C.SE_HELIACAL_SETTING},
+	{"EveningFirst", C.SE_EVENING_FIRST},
+	{"MorningLast", C.SE_MORNING_LAST},
+}
+
+// Parameters of swe_heliacal_ut describing the observer and the atmosphere.
+// Zero values are replaced by the swisseph defaults.
+type heliacalSettings struct {
+	geopos [3]float64
+	datm   [4]float64
+	dobs   [6]float64
+	flags  int
+}
+
+// Reads the heliacal settings from the query string
+func queryHeliacalSettings(q url.Values) heliacalSettings {
+	s := heliacalSettings{
+		geopos: [3]float64{queryFloat(q, "lon", 0), queryFloat(q, "lat", 0), queryFloat(q, "alt", 0)},
+		datm: [4]float64{
+			queryFloat(q, "atpress", 0),
+			queryFloat(q, "attemp", 0),
+			queryFloat(q, "humidity", 0),
+			queryFloat(q, "extinction", 0),
+		},
+		dobs: [6]float64{
+			queryFloat(q, "age", 0),
+			queryFloat(q, "snellen", 0),
+			queryFloat(q, "binocular", 0),
+			queryFloat(q, "magnification", 0),
+			queryFloat(q, "aperture", 0),
+			queryFloat(q, "transmission", 0),
+		},
+		flags: C.SEFLG_SWIEPH,
+	}
+
+	if q.Get("binocular") != "" || q.Get("magnification") != "" || q.Get("aperture") != "" || q.Get("transmission") != "" {
+		s.flags |= C.SE_HELFLAG_OPTICAL_PARAMS
+	}
+	if q.Get("high_precision") == "1" {
+		s.flags |= C.SE_HELFLAG_HIGH_PRECISION
+	}
+	if q.Get("long_search") == "1" {
+		s.flags |= C.SE_HELFLAG_LONG_SEARCH
+	}
+
+	return s
+}
+
+// Tells whether an event type exists for an object. Only the inferior
+// planets have all four events, the Moon has no heliacal rising and setting,
+// and the other planets and the stars have no evening first and morning last.
+func heliacalEventExists(object string, eventType int) bool {
+	switch strings.ToLower(object) {
+	case "mercury", "venus":
+		return true
+	case "moon":
+		return eventType == C.SE_EVENING_FIRST || eventType == C.SE_MORNING_LAST
+	}
+	return eventType == C.SE_HELIACAL_RISING || eventType == C.SE_HELIACAL_SETTING
+}
+
+// heliacalUT finds the next heliacal event of an object after jd, see
+// swe_heliacal_ut
+func heliacalUT(jd float64, object string, eventType int, s heliacalSettings) ([3]float64, error) {
+	var dret [50]C.double
+	serr := make([]byte, 256)
+	name := make([]byte, 2*C.SE_MAX_STNAME)
+	copy(name, object)
+
+	mu.Lock()
+	ret := C.swe_heliacal_ut(C.double(jd), (*C.double)(unsafe.Pointer(&s.geopos[0])),
+		(*C.double)(unsafe.Pointer(&s.datm[0])), (*C.double)(unsafe.Pointer(&s.dobs[0])),
+		(*C.char)(unsafe.Pointer(&name[0])), C.int32(eventType), C.int32(s.flags),
+		&dret[0], (*C.char)(unsafe.Pointer(&serr[0])))
+	mu.Unlock()
+
+	if ret < 0 {
+		return [3]float64{}, sweError(serr)
+	}
+
+	return [3]float64{float64(dret[0]), float64(dret[1]), float64(dret[2])}, nil
+}
+
+// parseHeliacalEvents reads a comma separated list of event names
+func parseHeliacalEvents(s string) (map[string]bool, error) {
+	events := make(map[string]bool)
+	for _, name := range strings.Split(s, ",") {
+		known := false
+		for _, e := range heliacalEvents {
+			known = known || e.name == name
+		}
+		if !known {
+			return nil, errors.New("unknown heliacal event: " + name)
+		}
+		events[name] = true
+	}
+	return events, nil
+}
+
+// HeliacalHandler lists the heliacal risings and settings, evening firsts and
+// morning lasts of a planet or a fixed star in a time range. Dates can be
+// given and returned in the julian calendar with cal=j.
+func HeliacalHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	s := queryHeliacalSettings(q)
+
+	h := &Heliacal{
+		Object:   q.Get("object"),
+		Lon:      s.geopos[0],
+		Lat:      s.geopos[1],
+		Alt:      s.geopos[2],
+		Calendar: "gregorian",
+	}
+	if h.Object == "" {
+		h.Object = "Venus"
+	}
+
+	gregflag := C.SE_GREG_CAL
+	if q.Get("cal") == "j" {
+		gregflag = C.SE_JUL_CAL
+		h.Calendar = "julian"
+	}
+
+	start := float64(C.swe_julday(C.int(queryInt(q, "year", 1970)), C.int(queryInt(q, "month", 1)),
+		C.int(queryInt(q, "day", 1)), C.double(queryFloat(q, "time", 0)), C.int(gregflag)))
+	end := start + 365.25
+	if q.Get("end_year") != "" {
+		end = float64(C.swe_julday(C.int(queryInt(q, "end_year", 1970)), C.int(queryInt(q, "end_month", 1)),
+			C.int(queryInt(q, "end_day", 1)), C.double(queryFloat(q, "end_time", 0)), C.int(gregflag)))
+	}
+
+	var events map[string]bool
+	if q.Get("events") != "" {
+		var err error
+		if events, err = parseHeliacalEvents(q.Get("events")); err != nil {
+			fmt.Printf("error: %v\n", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	for _, e := range heliacalEvents {
+		if events != nil && !events[e.name] {
+			continue
+		}
+		if events == nil && !heliacalEventExists(h.Object, e.eventType) {
+			continue
+		}
+
+		for t := start; t < end; {
+			dret, err := heliacalUT(t, h.Object, e.eventType, s)
+			if err != nil {
+				fmt.Printf("error: %v\n", err)
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if dret[0] > end {
+				break
+			}
+
+			event := HeliacalEvent{
+				XMLName: xml.Name{Local: e.name},
+				Start:   formatDate(dret[0], gregflag),
+				JD:      dret[0],
+			}
+			if dret[1] != 0 {
+				event.Optimum = formatDate(dret[1], gregflag)
+			}
+			if dret[2] != 0 {
+				event.End = formatDate(dret[2], gregflag)
+			}
+			h.Events = append(h.Events, event)
+
+			t = dret[0] + 1
+		}
+	}
+
+	sort.Slice(h.Events, func(i, j int) bool {
+		return h.Events[i].JD < h.Events[j].JD
+	})
+
+	writeXML(w, h)
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func Test_heliacalEventExists(t *testing.T) {
+	tests := []struct {
+		name      string
+		object    string
+		eventType int
+		want      bool
+	}{
+		{name: "Evening first of Venus", object: "Venus", eventType: 3, want: true},
+		{name: "Heliacal rising of the Moon", object: "Moon", eventType: 1, want: false},
+		{name: "Morning last of the Moon", object: "moon", eventType: 4, want: true},
+		{name: "Heliacal rising of Sirius", object: "Sirius", eventType: 1, want: true},
+		{name: "Evening first of Mars", object: "Mars", eventType: 3, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := heliacalEventExists(tt.object, tt.eventType); got != tt.want {
+				t.Errorf("heliacalEventExists() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHeliacalHandler(t *testing.T) {
+	sweSetEphePath("swe")
+	defer sweClose()
+
+	req, err := http.NewRequest("GET", "/heliacal?object=Sirius&year=-1500&cal=j&lat=30&lon=31&alt=10", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(HeliacalHandler)
+
+	handler.ServeHTTP(rr, req)
+
+	want := `<HeliacalRising start="-1500-07-19T02:17:51Z"`
+	if !strings.Contains(rr.Body.String(), want) {
+		t.Errorf("handler returned wrong xml: got %v want %v", rr.Body.String(), want)
+	}
+
+	req, err = http.NewRequest("GET", "/heliacal?object=Sirius&year=-1500&cal=j&lat=30&lon=31&alt=10&events=HeliacalSetting", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if strings.Contains(rr.Body.String(), "<HeliacalRising") || !strings.Contains(rr.Body.String(), "<HeliacalSetting") {
+		t.Errorf("handler returned wrong events: %v", rr.Body.String())
+	}
+
+	for _, url := range []string{"/heliacal?events=Heliacal", "/heliacal?events=HeliacalRisingSoon", "/heliacal?object=Nostar&events=HeliacalRising"} {
+		req, err = http.NewRequest("GET", url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr = httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%v returned status %v, want %v", url, rr.Code, http.StatusBadRequest)
+		}
+	}
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"net/url"
+)
+
+/*
+#include "swephexp.h"
+*/
+import "C"
+
+// Horary gives the considerations before judgement of a horary chart: the
+// strictures warning that the chart may not be fit to be judged, and the
+// perfection of the matter between the significators
+type Horary struct {
+	Querent       string       `xml:"querent,attr"`
+	Quesited      string       `xml:"quesited,attr"`
+	QuesitedHouse int          `xml:"quesited_house,attr"`
+	HourRuler     string       `xml:"hour_ruler,attr"`
+	Radical       bool         `xml:"radical,attr"`
+	Strictures    []Stricture  `xml:"strictures>Stricture"`
+	Perfections   []Perfection `xml:"perfections>Perfection"`
+}
+
+// Stricture is a consideration before judgement, present or not in the
+// chart
+type Stricture struct {
+	Name    string `xml:"name,attr"`
+	Present bool   `xml:"present,attr"`
+	Detail  string `xml:"detail,attr,omitempty"`
+}
+
+// Perfection is an aspect bringing two significators together, directly,
+// by translation of light from a faster planet or by collection of light by
+// a slower one
+type Perfection struct {
+	Kind   string  `xml:"kind,attr"`
+	Body1  string  `xml:"body1,attr"`
+	Body2  string  `xml:"body2,attr"`
+	By     string  `xml:"by,attr,omitempty"`
+	Aspect string  `xml:"aspect,attr"`
+	Orb    float64 `xml:"orb,attr"`
+	Days   float64 `xml:"days,attr"`
+}
+
+// The Via Combusta, from 15° Libra to 15° Scorpio
+const (
+	viaCombustaStart = 195
+	viaCombustaEnd   = 225
+)
+
+// The ascendant is too early or too late to judge within 3° of the cusp of
+// a sign
+const ascendantMargin = 3
+
+// movingPoint is a planet with its longitude and its daily speed
+type movingPoint struct {
+	name  string
+	lon   float64
+	speed float64
+}
+
+// aspectContact is the closest major aspect between two planets within
+// orb, applying or separating, with the time in days until it perfects,
+// negative once separating
+type aspectContact struct {
+	aspect aspectsetting
+	orb    float64
+	days   float64
+}
+
+// applying tells if the aspect will perfect before either planet leaves its
+// sign
+func (a aspectContact) applying(p1, p2 movingPoint) bool {
+	if a.days <= 0 {
+		return false
+	}
+	for _, p := range []movingPoint{p1, p2} {
+		sign, _ := signOf(p.lon)
+		if after, _ := signOf(p.lon + p.speed*a.days); after != sign {
+			return false
+		}
+	}
+	return true
+}
+
+// contact finds the closest major aspect between two planets within the
+// orb of the aspect
+func contact(p1, p2 movingPoint) (aspectContact, bool) {
+	best, found := aspectContact{}, false
+	separation := normalize180(p1.lon - p2.lon)
+	relative := p1.speed - p2.speed
+
+	for _, name := range majorAspects {
+		a, _ := aspectByName(name)
+		for _, target := range []float64{a.delta, -a.delta} {
+			diff := normalize180(target - separation)
+			if math.Abs(diff) > a.orb || (found && math.Abs(diff) >= best.orb) {
+				continue
+			}
+			// Planets at the same speed never perfect their aspect
+			days := 0.0
+			if relative != 0 {
+				days = diff / relative
+			}
+			best, found = aspectContact{aspect: a, orb: math.Abs(diff), days: days}, true
+		}
+	}
+
+	return best, found
+}
+
+// perfections finds how two significators come together: by an applying
+// aspect between them, by a faster planet separating from one and applying
+// to the other, or by a slower planet both apply to
+func perfections(p1, p2 movingPoint, planets []movingPoint) []Perfection {
+	var found []Perfection
+
+	if a, ok := contact(p1, p2); ok && a.applying(p1, p2) {
+		found = append(found, Perfection{Kind: "direct", Body1: p1.name, Body2: p2.name,
+			Aspect: a.aspect.title, Orb: a.orb, Days: a.days})
+	}
+
+	for _, p := range planets {
+		if p.name == p1.name || p.name == p2.name {
+			continue
+		}
+		a1, ok1 := contact(p, p1)
+		a2, ok2 := contact(p, p2)
+		if !ok1 || !ok2 {
+			continue
+		}
+
+		speed := math.Abs(p.speed)
+		faster := speed > math.Abs(p1.speed) && speed > math.Abs(p2.speed)
+		slower := speed < math.Abs(p1.speed) && speed < math.Abs(p2.speed)
+
+		switch {
+		case faster && a1.days < 0 && a2.applying(p, p2):
+			found = append(found, Perfection{Kind: "translation", Body1: p1.name, Body2: p2.name,
+				By: p.name, Aspect: a2.aspect.title, Orb: a2.orb, Days: a2.days})
+		case faster && a2.days < 0 && a1.applying(p, p1):
+			found = append(found, Perfection{Kind: "translation", Body1: p2.name, Body2: p1.name,
+				By: p.name, Aspect: a1.aspect.title, Orb: a1.orb, Days: a1.days})
+		case slower && a1.applying(p, p1) && a2.applying(p, p2):
+			last := a1
+			if a2.days > last.days {
+				last = a2
+			}
+			found = append(found, Perfection{Kind: "collection", Body1: p1.name, Body2: p2.name,
+				By: p.name, Aspect: last.aspect.title, Orb: last.orb, Days: last.days})
+		}
+	}
+
+	return found
+}
+
+// addHorary adds the considerations before judgement of a horary chart.
+// The querent is signified by the ruler of the ascendant and the Moon, the
+// quesited by the ruler of the house given by quesited, the 7th by default.
+func addHorary(c *ChartInfo, q url.Values) error {
+	if c.UnknownTime != nil {
+		return errors.New("horary charts need a known time")
+	}
+	if len(c.cusps) != 13 {
+		return errors.New("horary charts need twelve houses")
+	}
+	house := int(queryInt(q, "quesited", 7))
+	if house < 1 || house > 12 {
+		return errors.New("the quesited house must be between 1 and 12")
+	}
+
+	planets := make(map[string]movingPoint)
+	var list []movingPoint
+	for _, name := range traditionalPlanets {
+		id, _ := bodyID(name)
+		xx, err := c.bodyPosition(c.julday, id, C.SEFLG_SPEED)
+		if err != nil {
+			return err
+		}
+		p := movingPoint{name: name, lon: c.derived(xx[0]), speed: xx[3]}
+		planets[name] = p
+		list = append(list, p)
+	}
+
+	ascSign, ascDegree := signOf(c.ascmc[0])
+	quesitedSign, _ := signOf(c.cusps[house])
+	h := &Horary{Querent: domiciles[ascSign], Quesited: domiciles[quesitedSign], QuesitedHouse: house}
+
+	lat, lon := c.location()
+	ruler, err := hourRuler(c.julday, [3]float64{lon, lat, 0})
+	switch {
+	case err == errNoSunrise:
+		// There are no planetary hours in the polar day or night, so the
+		// agreement can't be judged
+		h.HourRuler = "none"
+		h.Strictures = append(h.Strictures, Stricture{Name: "hour_ruler",
+			Detail: "undeterminable, " + err.Error()})
+	case err != nil:
+		return err
+	default:
+		h.HourRuler = ruler
+
+		// The hour ruler agrees with the ascendant when it is its ruler, or
+		// when it rules a sign of the same triplicity
+		agrees := ruler == h.Querent
+		for sign, r := range domiciles {
+			if r == ruler && sign%4 == ascSign%4 {
+				agrees = true
+			}
+		}
+		h.Strictures = append(h.Strictures, Stricture{Name: "hour_ruler", Present: !agrees,
+			Detail: ruler + " hour, " + h.Querent + " rules the ascendant"})
+	}
+
+	h.Strictures = append(h.Strictures,
+		Stricture{Name: "early_ascendant", Present: ascDegree < ascendantMargin},
+		Stricture{Name: "late_ascendant", Present: ascDegree > 30-ascendantMargin})
+
+	void, p, err := isVoidOfCourse(c.julday, defaultVoidSettings())
+	if err != nil {
+		return err
+	}
+	s := Stricture{Name: "void_moon", Present: void}
+	if void {
+		s.Detail = "until " + p.End
+		if p.LastAspect != "" {
+			s.Detail = "after " + p.LastAspect + " " + p.Body + ", " + s.Detail
+		}
+	}
+	h.Strictures = append(h.Strictures, s)
+
+	moon := planets["Moon"].lon
+	h.Strictures = append(h.Strictures, Stricture{Name: "via_combusta",
+		Present: moon >= viaCombustaStart && moon < viaCombustaEnd})
+
+	saturn := houseOf(c, planets["Saturn"].lon)
+	h.Strictures = append(h.Strictures,
+		Stricture{Name: "saturn_in_1st", Present: saturn == 1},
+		Stricture{Name: "saturn_in_7th", Present: saturn == 7})
+
+	h.Radical = true
+	for _, s := range h.Strictures {
+		if s.Present {
+			h.Radical = false
+		}
+	}
+
+	querent, quesited := planets[h.Querent], planets[h.Quesited]
+	if h.Querent != h.Quesited {
+		h.Perfections = append(h.Perfections, perfections(querent, quesited, list)...)
+	}
+	if h.Querent != "Moon" && h.Quesited != "Moon" {
+		h.Perfections = append(h.Perfections, perfections(planets["Moon"], quesited, list)...)
+	}
+
+	c.Horary = h
+	return nil
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_contact(t *testing.T) {
+	tests := []struct {
+		name         string
+		p1, p2       movingPoint
+		wantAspect   string
+		wantApplying bool
+		wantFound    bool
+	}{
+		{"Applying conjunction", movingPoint{"Moon", 10, 13}, movingPoint{"Venus", 15, 1}, "Conjunction", true, true},
+		{"Separating conjunction", movingPoint{"Moon", 20, 13}, movingPoint{"Venus", 15, 1}, "Conjunction", false, true},
+		{"Applying trine", movingPoint{"Moon", 10, 13}, movingPoint{"Jupiter", 133, 0.1}, "Trine", true, true},
+		{"Perfecting in the next sign", movingPoint{"Moon", 28, 13}, movingPoint{"Venus", 35, 1}, "Conjunction", false, true},
+		{"Retrograde applying", movingPoint{"Mercury", 100, -1}, movingPoint{"Mars", 97, 0.5}, "Conjunction", true, true},
+		{"No aspect", movingPoint{"Moon", 10, 13}, movingPoint{"Venus", 50, 1}, "", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, found := contact(tt.p1, tt.p2)
+			if found != tt.wantFound || a.aspect.title != tt.wantAspect || a.applying(tt.p1, tt.p2) != tt.wantApplying {
+				t.Errorf("contact() = %v %v %v, want %v %v %v", a.aspect.title, a.applying(tt.p1, tt.p2), found,
+					tt.wantAspect, tt.wantApplying, tt.wantFound)
+			}
+		})
+	}
+}
+
+func Test_perfections(t *testing.T) {
+	tests := []struct {
+		name    string
+		p1, p2  movingPoint
+		planets []movingPoint
+		want    Perfection
+	}{
+		{
+			name: "Direct",
+			p1:   movingPoint{"Moon", 10, 13}, p2: movingPoint{"Venus", 15, 1},
+			want: Perfection{Kind: "direct", Body1: "Moon", Body2: "Venus", Aspect: "Conjunction", Orb: 5, Days: 5.0 / 12},
+		},
+		{
+			name: "Translation",
+			p1:   movingPoint{"Saturn", 10, 0.1}, p2: movingPoint{"Jupiter", 75, 0.2},
+			planets: []movingPoint{{"Saturn", 10, 0.1}, {"Jupiter", 75, 0.2}, {"Moon", 12, 13}},
+			want:    Perfection{Kind: "translation", Body1: "Saturn", Body2: "Jupiter", By: "Moon", Aspect: "Sextile", Orb: 3, Days: 3 / 12.8},
+		},
+		{
+			name: "Collection",
+			p1:   movingPoint{"Moon", 103, 13}, p2: movingPoint{"Mercury", 71, 1.5},
+			planets: []movingPoint{{"Moon", 103, 13}, {"Mercury", 71, 1.5}, {"Saturn", 199, 0.05}},
+			want:    Perfection{Kind: "collection", Body1: "Moon", Body2: "Mercury", By: "Saturn", Aspect: "Trine", Orb: 8, Days: 8 / 1.45},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := perfections(tt.p1, tt.p2, tt.planets)
+			if len(got) != 1 {
+				t.Fatalf("perfections() = %+v, want one", got)
+			}
+			g := got[0]
+			if g.Kind != tt.want.Kind || g.Body1 != tt.want.Body1 || g.Body2 != tt.want.Body2 || g.By != tt.want.By ||
+				g.Aspect != tt.want.Aspect || !almostEqual(g.Orb, tt.want.Orb) || !almostEqual(g.Days, tt.want.Days) {
+				t.Errorf("perfections() = %+v, want %+v", g, tt.want)
+			}
+		})
+	}
+}
+
+func almostEqual(a, b float64) bool {
+	d := a - b
+	return d < 1e-9 && d > -1e-9
+}
+
+func TestChartInfoHandlerHorary(t *testing.T) {
+	sweSetEphePath("swe")
+	defer sweClose()
+
+	req, err := http.NewRequest("GET", "/chartinfo?year=2024&month=1&day=2&time=23&lat=48.85&lon=2.35&hsys=R&horary=1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(ChartInfoHandler)
+
+	handler.ServeHTTP(rr, req)
+
+	var got ChartInfo
+	if err := xml.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+
+	h := got.Horary
+	if h == nil {
+		t.Fatalf("handler returned no horary considerations")
+	}
+	// 29° Virgo rises
+	if h.Querent != "Mercury" || h.Quesited != "Jupiter" || h.QuesitedHouse != 7 || len(h.Strictures) != 7 {
+		t.Fatalf("handler returned %v, %v, house %v and %v strictures, want Mercury, Jupiter, house 7 and 7 strictures",
+			h.Querent, h.Quesited, h.QuesitedHouse, len(h.Strictures))
+	}
+	if h.Radical || !h.Strictures[2].Present {
+		t.Errorf("handler returned a radical chart with a late ascendant")
+	}
+
+	want := Stricture{Name: "void_moon", Present: true, Detail: "after Square Mars, until 2024-01-03T00:46:45Z"}
+	if h.Strictures[3] != want {
+		t.Errorf("handler returned %+v, want %+v", h.Strictures[3], want)
+	}
+
+	// The sun doesn't set at midsummer in Svalbard
+	req, err = http.NewRequest("GET", "/chartinfo?year=2019&month=6&day=21&time=12&lat=78&lon=15&horary=1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	got = ChartInfo{}
+	if err := xml.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatalf("handler returned status %v: %v", rr.Code, rr.Body.String())
+	}
+	want = Stricture{Name: "hour_ruler", Detail: "undeterminable, the sun doesn't rise or set at this location"}
+	if got.Horary == nil || got.Horary.HourRuler != "none" || got.Horary.Strictures[0] != want {
+		t.Errorf("handler returned %+v, want hour ruler none and %+v", got.Horary, want)
+	}
+
+	for _, url := range []string{"/chartinfo?year=2024&hsys=G&horary=1", "/chartinfo?year=2024&unknown_time=noon&horary=1", "/chartinfo?year=2024&horary=1&quesited=13"} {
+		req, err = http.NewRequest("GET", url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr = httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%v returned status %v, want %v", url, rr.Code, http.StatusBadRequest)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+)
+
+/*
+#include "swephexp.h"
+*/
+import "C"
+
+// The seven traditional planets in the Chaldean order
+var chaldean = []string{"Saturn", "Jupiter", "Mars", "Sun", "Venus", "Mercury", "Moon"}
+
+// Rulers of the days of the week, starting with Sunday
+var dayRulers = []string{"Sun", "Moon", "Mars", "Mercury", "Jupiter", "Venus", "Saturn"}
+
+// PlanetaryHours is the root node of the planetary hours output
+type PlanetaryHours struct {
+	XMLName     xml.Name        `xml:"planetaryhours"`
+	Hours       []PlanetaryHour `xml:"Hour"`
+	DayRuler    string          `xml:"day_ruler,attr"`
+	Sunrise     string          `xml:"sunrise,attr"`
+	Sunset      string          `xml:"sunset,attr"`
+	NextSunrise string          `xml:"next_sunrise,attr"`
+	Lat         float64         `xml:"lat,attr"`
+	Lon         float64         `xml:"lon,attr"`
+}
+
+// PlanetaryHour is one of the 24 unequal hours of a planetary day
+type PlanetaryHour struct {
+	Number  int    `xml:"number,attr"`
+	Ruler   string `xml:"ruler,attr"`
+	Start   string `xml:"start,attr"`
+	End     string `xml:"end,attr"`
+	Daytime bool   `xml:"daytime,attr"`
+	start   float64
+	end     float64
+}
+
+// errNoSunrise is returned where the Sun stays above or below the horizon
+var errNoSunrise = errors.New("the sun doesn't rise or set at this location")
+
+// weekday returns the day of the week of a julian day at a given longitude,
+// in local mean time, starting with 0 for Sunday
+func weekday(jd float64, lon float64) int {
+	return int(math.Floor(jd+1.5+lon/360)) % 7
+}
+
+// Returns the ruler of the hour following the one ruled by planet
+func nextHourRuler(planet string) string {
+	for i, p := range chaldean {
+		if p == planet {
+			return chaldean[(i+1)%len(chaldean)]
+		}
+	}
+	return ""
+}
+
+// sunriseAfter returns the next sunrise after jd at a location
+func sunriseAfter(jd float64, geopos [3]float64) (float64, error) {
+	rise, ok, err := riseTrans(jd, C.SE_SUN, "", C.SE_CALC_RISE, geopos, 1013.25, 10)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return 0, errNoSunrise
+	}
+	return rise, nil
+}
+
+// planetaryDay computes the planetary hours of the day starting with the
+// last sunrise before jd
+func planetaryDay(jd float64, geopos [3]float64) (*PlanetaryHours, error) {
+	rise, err := sunriseAfter(jd-1.1, geopos)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		next, err := sunriseAfter(rise+0.01, geopos)
+		if err != nil {
+			return nil, err
+		}
+		// A second of margin for a jd given at the sunrise itself
+		if next > jd+1.0/86400 {
+			break
+		}
+		rise = next
+	}
+
+	return sunriseDay(rise, geopos)
+}
+
+// sunriseDay computes the planetary hours of the day starting with a
+// sunrise
+func sunriseDay(rise float64, geopos [3]float64) (*PlanetaryHours, error) {
+	next, err := sunriseAfter(rise+0.01, geopos)
+	if err != nil {
+		return nil, err
+	}
+	set, ok, err := riseTrans(rise, C.SE_SUN, "", C.SE_CALC_SET, geopos, 1013.25, 10)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errNoSunrise
+	}
+
+	p := &PlanetaryHours{
+		DayRuler:    dayRulers[weekday(rise, geopos[0])],
+		Sunrise:     utDate(rise),
+		Sunset:      utDate(set),
+		NextSunrise: utDate(next),
+		Lon:         geopos[0],
+		Lat:         geopos[1],
+	}
+
+	ruler := p.DayRuler
+	for i := 0; i < 24; i++ {
+		h := PlanetaryHour{Number: i + 1, Ruler: ruler, Daytime: i < 12}
+		if h.Daytime {
+			h.start = rise + float64(i)*(set-rise)/12
+			h.end = rise + float64(i+1)*(set-rise)/12
+		} else {
+			h.start = set + float64(i-12)*(next-set)/12
+			h.end = set + float64(i-11)*(next-set)/12
+		}
+		h.Start = utDate(h.start)
+		h.End = utDate(h.end)
+		p.Hours = append(p.Hours, h)
+		ruler = nextHourRuler(ruler)
+	}
+
+	return p, nil
+}
+
+// sunErrorStatus is the status answering an error of the sunrise search:
+// a location where the sun doesn't rise or set is a bad request
+func sunErrorStatus(err error) int {
+	if err == errNoSunrise {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// hourRuler returns the ruler of the planetary hour in effect at jd
+func hourRuler(jd float64, geopos [3]float64) (string, error) {
+	p, err := planetaryDay(jd, geopos)
+	if err != nil {
+		return "", err
+	}
+	for _, h := range p.Hours {
+		if jd >= h.start && jd < h.end {
+			return h.Ruler, nil
+		}
+	}
+	return "", nil
+}
+
+// PlanetaryHoursHandler returns the planetary hours and the day ruler for a
+// date and a location. The planetary day starts at the sunrise following
+// the local mean midnight.
+func PlanetaryHoursHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	geopos := [3]float64{queryFloat(q, "lon", 0), queryFloat(q, "lat", 0), queryFloat(q, "alt", 0)}
+
+	midnight := julday(queryInt(q, "year", 1970), queryInt(q, "month", 1), queryInt(q, "day", 1), 0) - geopos[0]/360
+	rise, err := sunriseAfter(midnight, geopos)
+	if err != nil {
+		fmt.Printf("error: %v\n", err)
+		http.Error(w, err.Error(), sunErrorStatus(err))
+		return
+	}
+
+	p, err := sunriseDay(rise, geopos)
+	if err != nil {
+		fmt.Printf("error: %v\n", err)
+		http.Error(w, err.Error(), sunErrorStatus(err))
+		return
+	}
+
+	writeXML(w, p)
+}
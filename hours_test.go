@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func Test_weekday(t *testing.T) {
+	tests := []struct {
+		name string
+		jd   float64
+		lon  float64
+		want int
+	}{
+		{name: "J2000 is a saturday", jd: 2451545.0, lon: 0, want: 6},
+		{name: "Late evening in Greenwich", jd: 2451545.45, lon: 0, want: 6},
+		{name: "Already sunday in Tokyo", jd: 2451545.45, lon: 139.7, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := weekday(tt.jd, tt.lon); got != tt.want {
+				t.Errorf("weekday() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_nextHourRuler(t *testing.T) {
+	tests := []struct {
+		name   string
+		planet string
+		want   string
+	}{
+		{name: "Saturn to Jupiter", planet: "Saturn", want: "Jupiter"},
+		{name: "Moon back to Saturn", planet: "Moon", want: "Saturn"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := nextHourRuler(tt.planet); got != tt.want {
+				t.Errorf("nextHourRuler() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlanetaryHoursHandler(t *testing.T) {
+	sweSetEphePath("swe")
+	defer sweClose()
+
+	req, err := http.NewRequest("GET", "/planetaryhours?year=2019&month=2&day=18&lat=48.85&lon=2.35", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(PlanetaryHoursHandler)
+
+	handler.ServeHTTP(rr, req)
+
+	for _, want := range []string{
+		`day_ruler="Moon" sunrise="2019-02-18T06:54:03Z"`,
+		`<Hour number="2" ruler="Saturn" start="2019-02-18T07:45:51Z"`,
+		`<Hour number="24" ruler="Jupiter"`,
+	} {
+		if !strings.Contains(rr.Body.String(), want) {
+			t.Errorf("handler returned wrong xml: got %v want %v", rr.Body.String(), want)
+		}
+	}
+
+	// The sun doesn't set at midsummer in Svalbard
+	req, err = http.NewRequest("GET", "/planetaryhours?year=2019&month=6&day=21&lat=78&lon=15", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("handler returned status %v for the polar day, want %v", rr.Code, http.StatusBadRequest)
+	}
+}
+
+func TestPlanetaryHoursHandlerDays(t *testing.T) {
+	sweSetEphePath("swe")
+	defer sweClose()
+
+	tests := []struct {
+		date string
+		want string
+	}{
+		{date: "year=2019&month=3&day=16", want: `day_ruler="Saturn" sunrise="2019-03-16T`},
+		{date: "year=2019&month=6&day=21", want: `day_ruler="Venus" sunrise="2019-06-21T`},
+		{date: "year=2019&month=7&day=14", want: `day_ruler="Sun" sunrise="2019-07-14T`},
+	}
+	for _, tt := range tests {
+		req, err := http.NewRequest("GET", "/planetaryhours?lat=48.85&lon=2.35&"+tt.date, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(PlanetaryHoursHandler).ServeHTTP(rr, req)
+
+		if !strings.Contains(rr.Body.String(), tt.want) {
+			t.Errorf("%v: handler returned %v, want %v", tt.date, rr.Body.String()[:200], tt.want)
+		}
+	}
+}
+
+func TestChartInfoHandlerPlanetaryHour(t *testing.T) {
+	sweSetEphePath("swe")
+	defer sweClose()
+
+	tests := []struct {
+		name string
+		url  string
+		want string
+	}{
+		{"Not requested", "/chartinfo?year=2019&month=2&day=18&time=16&lat=48.85&lon=2.35", ""},
+		{"Requested", "/chartinfo?year=2019&month=2&day=18&time=16&lat=48.85&lon=2.35&planetary_hour=1", "Mars"},
+		{"Equator", "/chartinfo?year=2019&month=2&day=18&time=16.083334&lat=0&lon=0&planetary_hour=1", "Jupiter"},
+		{"Polar day", "/chartinfo?year=2019&month=6&day=21&time=12&lat=78&lon=15&planetary_hour=1", "none"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.url, nil)
+			rr := httptest.NewRecorder()
+			http.HandlerFunc(ChartInfoHandler).ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusOK {
+				t.Fatalf("handler returned status %v", rr.Code)
+			}
+			var got ChartInfo
+			if err := xml.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			if got.PlanetaryHour != tt.want {
+				t.Errorf("handler returned planetary hour %q, want %q", got.PlanetaryHour, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"math"
+	"net/url"
+)
+
+/*
+#include "swephexp.h"
+*/
+import "C"
+
+// LocalSpace is the local space chart: the compass directions of the bodies
+// from the chart location, drawn as great circle lines on the globe
+type LocalSpace struct {
+	Lines []LocalSpaceLine `xml:"Line"`
+	Step  float64          `xml:"step,attr"`
+}
+
+// LocalSpaceLine is the line leaving the chart location towards the
+// azimuth of a body, with points every step degrees of arc up to the
+// antipode
+type LocalSpaceLine struct {
+	Body      string     `xml:"body,attr"`
+	Azimuth   float64    `xml:"azimuth,attr"`
+	Direction string     `xml:"direction,attr"`
+	Points    []GeoPoint `xml:"Point"`
+}
+
+// GeoPoint is a location on the globe
+type GeoPoint struct {
+	Distance float64 `xml:"distance,attr"`
+	Lat      float64 `xml:"lat,attr"`
+	Lon      float64 `xml:"lon,attr"`
+}
+
+// The sixteen points of the compass
+var compassPoints = []string{"N", "NNE", "NE", "ENE", "E", "ESE", "SE", "SSE",
+	"S", "SSW", "SW", "WSW", "W", "WNW", "NW", "NNW"}
+
+// compassPoint returns the point of the compass closest to an azimuth
+// measured from the north, eastward
+func compassPoint(azimuth float64) string {
+	return compassPoints[int(math.Floor(normalize(azimuth)/22.5+0.5))%len(compassPoints)]
+}
+
+// destination returns the point reached from a location by following a
+// great circle with an initial bearing, for a distance in degrees of arc
+func destination(lat, lon, bearing, distance float64) (float64, float64) {
+	lat1, lon1, b, d := lat*deg, lon*deg, bearing*deg, distance*deg
+	lat2 := math.Asin(math.Sin(lat1)*math.Cos(d) + math.Cos(lat1)*math.Sin(d)*math.Cos(b))
+	lon2 := lon1 + math.Atan2(math.Sin(b)*math.Sin(d)*math.Cos(lat1), math.Cos(d)-math.Sin(lat1)*math.Sin(lat2))
+	return lat2 / deg, normalize180(lon2 / deg)
+}
+
+// bodyHorizon returns the azimuth, from the north and eastward, the true
+// altitude and the apparent altitude of a body of a chart
+func bodyHorizon(c *ChartInfo, id int, atpress, attemp float64) ([3]float64, error) {
+	xx, err := c.bodyPosition(c.julday, id, 0)
+	if err != nil {
+		return [3]float64{}, err
+	}
+
+	lat, lon := c.location()
+	h := azalt(c.julday, [3]float64{lon, lat, 0}, atpress, attemp, xx[0], xx[1], xx[2])
+	h[0] = normalize(h[0] + 180)
+	return h, nil
+}
+
+// addHorizon gives the azimuth and the altitudes of the bodies of a chart
+// at its location. The apparent altitude is refracted for the atmospheric
+// pressure atpress and the temperature attemp.
+func addHorizon(c *ChartInfo, q url.Values) error {
+	atpress := queryFloat(q, "atpress", 1013.25)
+	attemp := queryFloat(q, "attemp", 10)
+
+	for i, b := range c.Bodies {
+		h, err := bodyHorizon(c, b.ID, atpress, attemp)
+		if err != nil {
+			return err
+		}
+		c.Bodies[i].Azimuth, c.Bodies[i].Altitude, c.Bodies[i].ApparentAltitude = h[0], h[1], h[2]
+	}
+
+	return nil
+}
+
+// addLocalSpace adds the local space lines of the bodies of a chart, with
+// points every local_space_step degrees of arc
+func addLocalSpace(c *ChartInfo, q url.Values) error {
+	ls := &LocalSpace{Step: queryFloat(q, "local_space_step", 30)}
+	if ls.Step <= 0 {
+		ls.Step = 30
+	}
+
+	fromLat, fromLon := c.location()
+	for _, b := range c.Bodies {
+		h, err := bodyHorizon(c, b.ID, 1013.25, 10)
+		if err != nil {
+			return err
+		}
+
+		line := LocalSpaceLine{Body: b.XMLName.Local, Azimuth: h[0], Direction: compassPoint(h[0])}
+		for d := 0.0; d <= 180; d += ls.Step {
+			lat, lon := destination(fromLat, fromLon, h[0], d)
+			line.Points = append(line.Points, GeoPoint{Distance: d, Lat: lat, Lon: lon})
+		}
+		ls.Lines = append(ls.Lines, line)
+	}
+
+	c.LocalSpace = ls
+	return nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func Test_compassPoint(t *testing.T) {
+	tests := []struct {
+		azimuth float64
+		want    string
+	}{
+		{0, "N"},
+		{11, "N"},
+		{12, "NNE"},
+		{90, "E"},
+		{186.25, "S"},
+		{350, "N"},
+		{-30, "NNW"},
+	}
+	for _, tt := range tests {
+		if got := compassPoint(tt.azimuth); got != tt.want {
+			t.Errorf("compassPoint(%v) = %v, want %v", tt.azimuth, got, tt.want)
+		}
+	}
+}
+
+func Test_destination(t *testing.T) {
+	tests := []struct {
+		name              string
+		lat, lon          float64
+		bearing, distance float64
+		wantLat, wantLon  float64
+	}{
+		{name: "North to the pole", lat: 45, lon: 10, bearing: 0, distance: 45, wantLat: 90, wantLon: 10},
+		{name: "East on the equator", lat: 0, lon: 170, bearing: 90, distance: 20, wantLat: 0, wantLon: -170},
+		{name: "Antipode", lat: 48.85, lon: 2.35, bearing: 123, distance: 180, wantLat: -48.85, wantLon: -177.65},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lat, lon := destination(tt.lat, tt.lon, tt.bearing, tt.distance)
+			if math.Abs(lat-tt.wantLat) > 1e-6 || (math.Abs(tt.wantLat) < 90 && math.Abs(lon-tt.wantLon) > 1e-6) {
+				t.Errorf("destination() = %v, %v, want %v, %v", lat, lon, tt.wantLat, tt.wantLon)
+			}
+		})
+	}
+}
+
+func TestChartInfoHandlerLocalSpace(t *testing.T) {
+	sweSetEphePath("swe")
+	defer sweClose()
+
+	req := httptest.NewRequest("GET", "/chartinfo?year=1980&month=5&day=17&time=12&lat=48.85&lon=2.35&display=0,1,23&azalt=1&local_space=1&local_space_step=90", nil)
+	w := httptest.NewRecorder()
+	ChartInfoHandler(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("ChartInfoHandler() status = %v", w.Code)
+	}
+
+	for _, s := range []string{
+		`id="0" azimuth="186.25`,
+		`altitude="60.45`,
+		`apparent_altitude="60.46`,
+		`<local_space step="90">`,
+		`<Line body="Moon" azimuth="119.16`,
+		`direction="ESE"`,
+		`<Point distance="180" lat="-48.85`,
+	} {
+		if !strings.Contains(w.Body.String(), s) {
+			t.Errorf("ChartInfoHandler() output missing %s", s)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Lots is the optional section listing the lots of a chart
+type Lots struct {
+	Lots []Lot `xml:",any"`
+}
+
+// Lot represents an Arabic part or Hellenistic lot
+type Lot struct {
+	XMLName  xml.Name
+	Formula  string  `xml:"formula,attr"`
+	SignName string  `xml:"sign_name,attr"`
+	DegreeUt float64 `xml:"degree_ut,attr"`
+	Degree   float64 `xml:"degree,attr"`
+	Sign     int     `xml:"sign,attr"`
+	House    int     `xml:"house,attr"`
+}
+
+// A lot is the distance from c to b projected from a, for diurnal charts
+type lotFormula struct {
+	name string
+	a    string
+	b    string
+	c    string
+}
+
+// The seven lots of Paulus Alexandrinus
+var lotFormulas = []lotFormula{
+	{"Fortune", "Ascendant", "Moon", "Sun"},
+	{"Spirit", "Ascendant", "Sun", "Moon"},
+	{"Eros", "Ascendant", "Venus", "Spirit"},
+	{"Necessity", "Ascendant", "Fortune", "Mercury"},
+	{"Courage", "Ascendant", "Fortune", "Mars"},
+	{"Victory", "Ascendant", "Jupiter", "Spirit"},
+	{"Nemesis", "Ascendant", "Fortune", "Saturn"},
+}
+
+// parseLotFormula reads a user defined lot of the form Name:A+B-C
+func parseLotFormula(s string) (lotFormula, error) {
+	s = strings.Replace(s, "−", "-", -1)
+
+	colon := strings.Index(s, ":")
+	plus := strings.Index(s, "+")
+	minus := strings.LastIndex(s, "-")
+	if colon < 1 || plus < colon+2 || minus < plus+2 || minus == len(s)-1 {
+		return lotFormula{}, fmt.Errorf("invalid lot formula %q", s)
+	}
+
+	return lotFormula{
+		name: strings.TrimSpace(s[:colon]),
+		a:    strings.TrimSpace(s[colon+1 : plus]),
+		b:    strings.TrimSpace(s[plus+1 : minus]),
+		c:    strings.TrimSpace(s[minus+1:]),
+	}, nil
+}
+
+// queryLotFormulas reads the lots requested with lots=1 or lots=Fortune,Spirit
+// and the user defined lots given with lot=Name:A+B-C
+func queryLotFormulas(q url.Values) ([]lotFormula, error) {
+	var formulas []lotFormula
+
+	names := strings.Split(q.Get("lots"), ",")
+	for _, f := range lotFormulas {
+		for _, name := range names {
+			if name == "1" || name == f.name {
+				formulas = append(formulas, f)
+				break
+			}
+		}
+	}
+
+	for _, s := range q["lot"] {
+		f, err := parseLotFormula(s)
+		if err != nil {
+			return nil, err
+		}
+		formulas = append(formulas, f)
+	}
+
+	return formulas, nil
+}
+
+// withDependencies puts before each formula the lots of Paulus it uses,
+// like Fortune and Spirit, when they are not requested
+func withDependencies(formulas []lotFormula) []lotFormula {
+	var all []lotFormula
+	added := make(map[string]bool)
+
+	var add func(f lotFormula)
+	add = func(f lotFormula) {
+		if added[f.name] {
+			return
+		}
+		added[f.name] = true
+		for _, name := range []string{f.a, f.b, f.c} {
+			for _, d := range lotFormulas {
+				if d.name == name {
+					add(d)
+				}
+			}
+		}
+		all = append(all, f)
+	}
+
+	for _, f := range formulas {
+		add(f)
+	}
+	return all
+}
+
+// addLots computes the lots of a chart. The formulas are reversed for
+// nocturnal charts. Lots can be used in the formulas of the lots that follow,
+// and the lots of Paulus used by a formula are computed without being output.
+func addLots(c *ChartInfo, formulas []lotFormula) error {
+	if c.UnknownTime != nil {
+		return errors.New("lots need a known birth time")
+	}
+
+	points, err := chartPoints(c)
+	if err != nil {
+		return err
+	}
+
+	diurnal, err := isDiurnal(c)
+	if err != nil {
+		return err
+	}
+	c.Sect = "night"
+	if diurnal {
+		c.Sect = "day"
+	}
+	c.Lots = &Lots{}
+
+	requested := make(map[string]bool)
+	for _, f := range formulas {
+		requested[f.name] = true
+	}
+
+	for _, f := range withDependencies(formulas) {
+		b, cc := f.b, f.c
+		if !diurnal {
+			b, cc = cc, b
+		}
+
+		for _, name := range []string{f.a, b, cc} {
+			if _, ok := points[name]; !ok {
+				return fmt.Errorf("unknown point %q in lot %v", name, f.name)
+			}
+		}
+
+		degreeUt := normalize(points[f.a] + points[b] - points[cc])
+		points[f.name] = degreeUt
+		if !requested[f.name] {
+			continue
+		}
+
+		sign, degree := signOf(degreeUt)
+		c.Lots.Lots = append(c.Lots.Lots, Lot{
+			XMLName:  xml.Name{Local: f.name},
+			Formula:  f.a + "+" + b + "-" + cc,
+			SignName: snames[sign],
+			DegreeUt: degreeUt,
+			Degree:   degree,
+			Sign:     sign,
+			House:    houseOf(c, degreeUt),
+		})
+	}
+
+	// Lots are aspect targets for the bodies
+	ascendant := c.AscMCs[0].DegreeUt
+	for _, body := range c.Bodies {
+		for _, lot := range c.Lots.Lots {
+			target := Body{XMLName: lot.XMLName, DegreeUt: lot.DegreeUt}
+			for _, s := range aspectsettings {
+				aspect := makeAspect(body, target, ascendant, s.delta, s.orb, s.title)
+				if aspect == (Aspect{}) {
+					aspect = makeAspect(target, body, ascendant, s.delta, s.orb, s.title)
+				}
+				if aspect != (Aspect{}) {
+					c.Aspects = append(c.Aspects, aspect)
+				}
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_parseLotFormula(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    lotFormula
+		wantErr bool
+	}{
+		{name: "Simple formula", s: "Marriage:Ascendant+Venus-Saturn", want: lotFormula{"Marriage", "Ascendant", "Venus", "Saturn"}},
+		{name: "Unicode minus", s: "Father:Ascendant+Saturn−Sun", want: lotFormula{"Father", "Ascendant", "Saturn", "Sun"}},
+		{name: "Hyphenated point", s: "Test:MC+Co-Ascendant1-Moon", want: lotFormula{"Test", "MC", "Co-Ascendant1", "Moon"}},
+		{name: "Missing name", s: "Ascendant+Venus-Saturn", wantErr: true},
+		{name: "Missing operand", s: "Marriage:Ascendant+Venus", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseLotFormula(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseLotFormula() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseLotFormula() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChartInfoHandlerLots(t *testing.T) {
+	sweSetEphePath("swe")
+	defer sweClose()
+
+	tests := []struct {
+		name     string
+		url      string
+		want     []string
+		dontWant []string
+	}{
+		{
+			name: "Diurnal chart",
+			url:  "/chartinfo?year=2019&month=2&day=18&time=16.083334&lat=48&lon=2&lots=Fortune,Spirit&lot=Marriage:Ascendant%2BVenus-Saturn",
+			want: []string{
+				`sect="day"`,
+				`<Fortune formula="Ascendant+Moon-Sun"`,
+				`<Marriage formula="Ascendant+Venus-Saturn"`,
+			},
+		},
+		{
+			name: "Nocturnal chart",
+			url:  "/chartinfo?year=2019&month=2&day=18&time=23&lat=48&lon=2&lots=1",
+			want: []string{
+				`sect="night"`,
+				`<Fortune formula="Ascendant+Sun-Moon"`,
+				`<Nemesis formula="Ascendant+Saturn-Fortune"`,
+			},
+		},
+		{
+			name: "Lots depending on lots not requested",
+			url:  "/chartinfo?year=2019&month=2&day=18&time=16.083334&lat=48&lon=2&lots=Eros,Necessity",
+			want: []string{
+				`<Eros formula="Ascendant+Venus-Spirit"`,
+				`<Necessity formula="Ascendant+Fortune-Mercury"`,
+			},
+			dontWant: []string{`<Fortune `, `<Spirit `},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("GET", tt.url, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rr := httptest.NewRecorder()
+			handler := http.HandlerFunc(ChartInfoHandler)
+			handler.ServeHTTP(rr, req)
+
+			for _, want := range tt.want {
+				if !strings.Contains(rr.Body.String(), want) {
+					t.Errorf("handler returned wrong xml: got %v want %v", rr.Body.String(), want)
+				}
+			}
+			for _, s := range tt.dontWant {
+				if strings.Contains(rr.Body.String(), s) {
+					t.Errorf("handler returned unrequested lot %v", s)
+				}
+			}
+		})
+	}
+
+	for _, url := range []string{"/chartinfo?lot=Marriage", "/chartinfo?lot=Marriage:Ascendant%2BVenus-Vulcan"} {
+		req, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(ChartInfoHandler).ServeHTTP(rr, req)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%v returned status %v, want %v", url, rr.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestChartInfoHandlerLotsGauquelin(t *testing.T) {
+	sweSetEphePath("swe")
+	defer sweClose()
+
+	req, err := http.NewRequest("GET", "/chartinfo?year=2019&month=2&day=18&time=23&lat=48&lon=2&hsys=G&lots=1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(ChartInfoHandler).ServeHTTP(rr, req)
+
+	var got ChartInfo
+	if err := xml.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Sect != "night" {
+		t.Errorf("handler returned sect %v, want night", got.Sect)
+	}
+	houses := make(map[int]bool)
+	for _, lot := range got.Lots.Lots {
+		if lot.XMLName.Local == "Fortune" && lot.Formula != "Ascendant+Sun-Moon" {
+			t.Errorf("handler returned Fortune as %v, want Ascendant+Sun-Moon", lot.Formula)
+		}
+		if lot.House < 1 || lot.House > 36 {
+			t.Errorf("handler returned %v in sector %v", lot.XMLName.Local, lot.House)
+		}
+		houses[lot.House] = true
+	}
+	if len(houses) < 2 {
+		t.Errorf("handler returned every lot in the same sector: %v", houses)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"log"
 	"math"
 	"net/http"
+	"net/url"
 	"os"
 	"sort"
 	"strconv"
@@ -63,23 +64,63 @@ var aspectsettings = []aspectsetting{
 	{0, 10, "Conjunction"},
 }
 
+// aspectByName finds the setting of an aspect by name
+func aspectByName(name string) (aspectsetting, bool) {
+	for _, a := range aspectsettings {
+		if strings.EqualFold(a.title, name) {
+			return a, true
+		}
+	}
+	return aspectsetting{}, false
+}
+
 // ChartInfo is the root node of our xml output
 type ChartInfo struct {
-	XMLName xml.Name `xml:"chartinfo"`
-	AscMCs  []AscMC  `xml:"ascmcs>AscMC"`
-	Houses  []House  `xml:"houses>House"`
-	Aspects []Aspect `xml:"aspects>Aspect"`
-	Bodies  []Body   `xml:"bodies>Body"`
-	Display string   `xml:"display,attr,omitempty"`
-	Year    int64    `xml:"year,attr,omitempty"`
-	Month   int64    `xml:"month,attr,omitempty"`
-	Day     int64    `xml:"day,attr,omitempty"`
-	Time    float64  `xml:"time,attr,omitempty"`
-	Lat     float64  `xml:"lat,attr,omitempty"`
-	Lon     float64  `xml:"lon,attr,omitempty"`
-	Name    string   `xml:"name,attr,omitempty"`
-	City    string   `xml:"city,attr,omitempty"`
-	Hsys    string   `xml:"hsys,attr,omitempty"`
+	XMLName   xml.Name   `xml:"chartinfo"`
+	AscMCs    []AscMC    `xml:"ascmcs>AscMC"`
+	Houses    []House    `xml:"houses>House"`
+	Aspects   []Aspect   `xml:"aspects>Aspect"`
+	Bodies    []Body     `xml:"bodies>Body"`
+	Lots      *Lots      `xml:"lots,omitempty"`
+	Dignities *Dignities `xml:"dignities,omitempty"`
+	Midpoints *Midpoints `xml:"midpoints,omitempty"`
+	Antiscia  *Antiscia  `xml:"antiscia,omitempty"`
+	Display   string     `xml:"display,attr,omitempty"`
+	Year      int64      `xml:"year,attr,omitempty"`
+	Month     int64      `xml:"month,attr,omitempty"`
+	Day       int64      `xml:"day,attr,omitempty"`
+	Time      float64    `xml:"time,attr,omitempty"`
+	Lat       float64    `xml:"lat,attr,omitempty"`
+	Lon       float64    `xml:"lon,attr,omitempty"`
+	Name      string     `xml:"name,attr,omitempty"`
+	City      string     `xml:"city,attr,omitempty"`
+	Hsys      string     `xml:"hsys,attr,omitempty"`
+
+	Ayanamsa      string  `xml:"ayanamsa,attr,omitempty"`
+	AyanamsaValue float64 `xml:"ayanamsa_value,attr,omitempty"`
+	Derive        string  `xml:"derive,attr,omitempty"`
+	Harmonic      int64   `xml:"harmonic,attr,omitempty"`
+	DraconicNode  string  `xml:"draconic_node,attr,omitempty"`
+	PlanetaryHour string  `xml:"planetary_hour,attr,omitempty"`
+	Sect          string  `xml:"sect,attr,omitempty"`
+
+	UnknownTime *UnknownTime `xml:"unknown_time,omitempty"`
+	LocalSpace  *LocalSpace  `xml:"local_space,omitempty"`
+	Relocation  *Relocation  `xml:"relocation,omitempty"`
+
+	NodesApsides *NodesApsides `xml:"nodes_apsides,omitempty"`
+
+	Horary *Horary `xml:"horary,omitempty"`
+
+	julday float64
+	cusps  []float64
+	ascmc  [10]float64
+
+	sidereal  bool
+	sidmode   int
+	transform func(float64) float64
+
+	nodApsMethod int
 }
 
 // AscMC represents special marks like the ascendants
@@ -112,6 +153,25 @@ type Body struct {
 	Sign       int     `xml:"sign,attr"`
 	Retrograde bool    `xml:"retrograde,attr"`
 	ID         int     `xml:"id,attr"`
+
+	Nakshatra     string `xml:"nakshatra,attr,omitempty"`
+	Pada          int    `xml:"pada,attr,omitempty"`
+	NakshatraLord string `xml:"nakshatra_lord,attr,omitempty"`
+
+	Azimuth          float64 `xml:"azimuth,attr,omitempty"`
+	Altitude         float64 `xml:"altitude,attr,omitempty"`
+	ApparentAltitude float64 `xml:"apparent_altitude,attr,omitempty"`
+
+	PhaseAngle   float64 `xml:"phase_angle,attr,omitempty"`
+	Illumination float64 `xml:"illumination,attr,omitempty"`
+	Elongation   float64 `xml:"elongation,attr,omitempty"`
+	Diameter     float64 `xml:"apparent_diameter,attr,omitempty"`
+	Magnitude    float64 `xml:"magnitude,attr,omitempty"`
+	Combustion   string  `xml:"combustion,attr,omitempty"`
+	Appearance   string  `xml:"appearance,attr,omitempty"`
+	Visibility   string  `xml:"visibility,attr,omitempty"`
+
+	Dignity *Dignity `xml:"dignity,omitempty"`
 }
 
 // Aspect represents a astrological aspect like a Conjunction or a Sextile
@@ -121,6 +181,8 @@ type Aspect struct {
 	Body2   string  `xml:"body2,attr"`
 	Degree1 float64 `xml:"degree1,attr"`
 	Degree2 float64 `xml:"degree2,attr"`
+
+	Uncertain bool `xml:"uncertain,attr,omitempty"`
 }
 
 var mu sync.Mutex
@@ -148,6 +210,68 @@ func sliceAtoi(sa []string) ([]int, error) {
 	return si, nil
 }
 
+// planetIDs reads a comma separated list of body numbers, which must be
+// planets known to the ephemeris
+func planetIDs(s string) ([]int, error) {
+	ids, err := sliceAtoi(strings.Split(s, ","))
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		if id < 0 || id >= C.SE_NPLANETS {
+			return nil, fmt.Errorf("unknown body id: %d", id)
+		}
+	}
+	return ids, nil
+}
+
+// Reads an integer from the query string, or returns def when it is absent
+func queryInt(q url.Values, key string, def int64) int64 {
+	if q.Get(key) == "" {
+		return def
+	}
+
+	i, err := strconv.ParseInt(q.Get(key), 10, 64)
+	if err != nil {
+		fmt.Printf("error: %v\n", err)
+		return def
+	}
+
+	return i
+}
+
+// Reads a float from the query string, or returns def when it is absent
+func queryFloat(q url.Values, key string, def float64) float64 {
+	if q.Get(key) == "" {
+		return def
+	}
+
+	f, err := strconv.ParseFloat(q.Get(key), 64)
+	if err != nil {
+		fmt.Printf("error: %v\n", err)
+		return def
+	}
+
+	return f
+}
+
+// queryRange reads a time range as julian days. The start is given by year,
+// month, day and time, the end by the same keys prefixed with end_. The range
+// spans one year when no end is given.
+func queryRange(q url.Values) (float64, float64) {
+	start := julday(queryInt(q, "year", 1970), queryInt(q, "month", 1),
+		queryInt(q, "day", 1), queryFloat(q, "time", 0))
+
+	if q.Get("end_year") == "" {
+		return start, start + 365.25
+	}
+
+	end := julday(queryInt(q, "end_year", 1970), queryInt(q, "end_month", 1),
+		queryInt(q, "end_day", 1), queryFloat(q, "end_time", 0))
+
+	return start, end
+}
+
 // Make sure angle values are in within the 0 to 360 range
 func normalize(angle float64) float64 {
 	angle = math.Mod(angle, 360)
@@ -157,6 +281,34 @@ func normalize(angle float64) float64 {
 	return angle
 }
 
+// signOf returns the sign of an ecliptic longitude and the degree in that sign
+func signOf(degreeUt float64) (int, float64) {
+	degreeUt = normalize(degreeUt)
+	sign := int(degreeUt / 30)
+	return sign, degreeUt - float64(sign*30)
+}
+
+// angleDiff returns the shortest distance between two angles, from 0 to 180
+func angleDiff(a, b float64) float64 {
+	d := normalize(a - b)
+	if d > 180 {
+		d = 360 - d
+	}
+	return d
+}
+
+// writeXML marshals v and writes it as an xml document
+func writeXML(w http.ResponseWriter, v interface{}) {
+	out, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		fmt.Printf("error: %v\n", err)
+	}
+
+	w.Header().Set("Content-Type", "text/xml; charset=UTF-8")
+	out = []byte("<?xml version='1.0' encoding='UTF-8'?>" + string(out))
+	w.Write(out)
+}
+
 // makeAspect returns an Aspect for a given orb and two celectial bodies
 func makeAspect(body1 Body, body2 Body, ascendant float64, delta float64, orb float64, t string) (aspect Aspect) {
 	deg1 := normalize(body1.DegreeUt - ascendant + 180)
@@ -183,15 +335,11 @@ func makeAspect(body1 Body, body2 Body, ascendant float64, delta float64, orb fl
 	return
 }
 
-// ChartInfoHandler returns houses and planet positions for a location and time
-func ChartInfoHandler(w http.ResponseWriter, r *http.Request) {
+// parseChartInfo reads the chart parameters from a query string. The prefix
+// allows reading a second chart, like a natal chart, from the same query.
+func parseChartInfo(q url.Values, prefix string) (*ChartInfo, []int, error) {
 	var c = &ChartInfo{}
 
-	var xx [6]C.double
-	serr := make([]byte, 256)
-	var julday C.double
-	var cusp [37]C.double
-	var ascmc [10]C.double
 	c.Hsys = "E"
 	c.Year = 1970
 	c.Month = 1
@@ -202,12 +350,12 @@ func ChartInfoHandler(w http.ResponseWriter, r *http.Request) {
 		display[i] = i
 	}
 
-	if r.URL.Query().Get("hsys") != "" {
-		c.Hsys = r.URL.Query().Get("hsys")
+	if q.Get(prefix+"hsys") != "" {
+		c.Hsys = q.Get(prefix + "hsys")
 	}
 
-	if r.URL.Query().Get("year") != "" {
-		i, err := strconv.ParseInt(r.URL.Query().Get("year"), 10, 64)
+	if q.Get(prefix+"year") != "" {
+		i, err := strconv.ParseInt(q.Get(prefix+"year"), 10, 64)
 
 		if err != nil {
 			fmt.Printf("error: %v\n", err)
@@ -216,8 +364,8 @@ func ChartInfoHandler(w http.ResponseWriter, r *http.Request) {
 		c.Year = i
 	}
 
-	if r.URL.Query().Get("month") != "" {
-		i, err := strconv.ParseInt(r.URL.Query().Get("month"), 10, 64)
+	if q.Get(prefix+"month") != "" {
+		i, err := strconv.ParseInt(q.Get(prefix+"month"), 10, 64)
 
 		if err != nil {
 			fmt.Printf("error: %v\n", err)
@@ -226,8 +374,8 @@ func ChartInfoHandler(w http.ResponseWriter, r *http.Request) {
 		c.Month = i
 	}
 
-	if r.URL.Query().Get("day") != "" {
-		i, err := strconv.ParseInt(r.URL.Query().Get("day"), 10, 64)
+	if q.Get(prefix+"day") != "" {
+		i, err := strconv.ParseInt(q.Get(prefix+"day"), 10, 64)
 
 		if err != nil {
 			fmt.Printf("error: %v\n", err)
@@ -236,8 +384,8 @@ func ChartInfoHandler(w http.ResponseWriter, r *http.Request) {
 		c.Day = i
 	}
 
-	if r.URL.Query().Get("time") != "" {
-		i, err := strconv.ParseFloat(r.URL.Query().Get("time"), 64)
+	if q.Get(prefix+"time") != "" {
+		i, err := strconv.ParseFloat(q.Get(prefix+"time"), 64)
 
 		if err != nil {
 			fmt.Printf("error: %v\n", err)
@@ -246,8 +394,8 @@ func ChartInfoHandler(w http.ResponseWriter, r *http.Request) {
 		c.Time = i
 	}
 
-	if r.URL.Query().Get("lat") != "" {
-		i, err := strconv.ParseFloat(r.URL.Query().Get("lat"), 64)
+	if q.Get(prefix+"lat") != "" {
+		i, err := strconv.ParseFloat(q.Get(prefix+"lat"), 64)
 
 		if err != nil {
 			fmt.Printf("error: %v\n", err)
@@ -256,8 +404,8 @@ func ChartInfoHandler(w http.ResponseWriter, r *http.Request) {
 		c.Lat = i
 	}
 
-	if r.URL.Query().Get("lon") != "" {
-		i, err := strconv.ParseFloat(r.URL.Query().Get("lon"), 64)
+	if q.Get(prefix+"lon") != "" {
+		i, err := strconv.ParseFloat(q.Get(prefix+"lon"), 64)
 
 		if err != nil {
 			fmt.Printf("error: %v\n", err)
@@ -266,8 +414,8 @@ func ChartInfoHandler(w http.ResponseWriter, r *http.Request) {
 		c.Lon = i
 	}
 
-	if r.URL.Query().Get("display") != "" {
-		c.Display = r.URL.Query().Get("display")
+	if q.Get(prefix+"display") != "" {
+		c.Display = q.Get(prefix + "display")
 
 		d, err := sliceAtoi(strings.Split(c.Display, ","))
 
@@ -278,8 +426,34 @@ func ChartInfoHandler(w http.ResponseWriter, r *http.Request) {
 		display = d
 	}
 
-	c.Name = r.URL.Query().Get("name")
-	c.City = r.URL.Query().Get("city")
+	c.Name = q.Get(prefix + "name")
+	c.City = q.Get(prefix + "city")
+
+	if err := parseSidereal(q, prefix, c); err != nil {
+		return c, display, err
+	}
+	if err := parseDerive(q, prefix, c); err != nil {
+		return c, display, err
+	}
+	if err := parseUnknownTime(q, prefix, c); err != nil {
+		return c, display, err
+	}
+	if err := parseRelocation(q, prefix, c); err != nil {
+		return c, display, err
+	}
+	if err := parseNodAps(q, prefix, c); err != nil {
+		return c, display, err
+	}
+
+	return c, display, nil
+}
+
+// castChart computes the houses, bodies and aspects of a chart
+func castChart(c *ChartInfo, display []int) error {
+	var xx [6]C.double
+	serr := make([]byte, 256)
+	var cusp [37]C.double
+	var ascmc [10]C.double
 
 	// The number of houses is 12 except when using Gauquelin sectors
 	var numhouses = 12
@@ -287,11 +461,23 @@ func ChartInfoHandler(w http.ResponseWriter, r *http.Request) {
 		numhouses = 36
 	}
 
-	julday = C.swe_julday(C.int(c.Year), C.int(c.Month), C.int(c.Day), C.double(c.Time), C.SE_GREG_CAL)
+	julday := C.swe_julday(C.int(c.Year), C.int(c.Month), C.int(c.Day), C.double(c.Time), C.SE_GREG_CAL)
+
+	// Relocated charts have their houses cast for another location
+	lat, lon := c.location()
 
-	C.swe_set_topo(C.double(c.Lat), C.double(c.Lon), 0)
+	C.swe_set_topo(C.double(lat), C.double(lon), 0)
 
-	C.swe_houses(julday, C.double(c.Lat), C.double(c.Lon), C.int(rune(c.Hsys[0])), (*C.double)(&cusp[0]), (*C.double)(&ascmc[0]))
+	C.swe_houses(julday, C.double(lat), C.double(lon), C.int(rune(c.Hsys[0])), (*C.double)(&cusp[0]), (*C.double)(&ascmc[0]))
+
+	c.julday = float64(julday)
+	c.cusps = make([]float64, numhouses+1)
+	for i := range c.cusps {
+		c.cusps[i] = float64(cusp[i])
+	}
+	for i := range c.ascmc {
+		c.ascmc[i] = float64(ascmc[i])
+	}
 
 	// Add ascendant and other marks to the chart
 	for index := 0; index < C.SE_NASCMC; index++ {
@@ -340,7 +526,7 @@ func ChartInfoHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Add celestial bodies to the chart
-	for body := C.int32(0); body < C.SE_NPLANETS+2; body++ {
+	for body := C.int32(0); int(body) < len(bnames); body++ {
 
 		if !contains(display[:], int(body)) {
 			continue
@@ -348,7 +534,14 @@ func ChartInfoHandler(w http.ResponseWriter, r *http.Request) {
 
 		var degreeUt float64
 		var ret C.int32
-		if body == 23 {
+		if body >= firstNodApsID {
+			pos, err := c.bodyPosition(float64(julday), int(body), 0)
+			if err != nil {
+				return err
+			}
+			degreeUt = pos[0]
+			xx[3] = C.double(pos[3])
+		} else if body == 23 {
 			mu.Lock()
 			ret = C.swe_calc_ut(julday, 10, 0, &xx[0], (*C.char)(unsafe.Pointer(&serr[0])))
 			mu.Unlock()
@@ -366,7 +559,7 @@ func ChartInfoHandler(w http.ResponseWriter, r *http.Request) {
 		}
 
 		if ret < 0 {
-			log.Fatal(string(serr))
+			return sweError(serr)
 		}
 
 		retrograde := xx[3] < 0
@@ -391,6 +584,18 @@ func ChartInfoHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// Sidereal and derived charts are transformed before the aspects are found
+	if c.sidereal {
+		if err := siderealChart(c); err != nil {
+			return err
+		}
+	}
+	if c.Derive != "" {
+		if err := deriveChart(c); err != nil {
+			return err
+		}
+	}
+
 	// Ascpects
 	for _, body1 := range c.Bodies {
 		for _, body2 := range c.Bodies {
@@ -423,14 +628,196 @@ func ChartInfoHandler(w http.ResponseWriter, r *http.Request) {
 		oldDeg = deg
 	}
 
-	out, err := xml.MarshalIndent(c, "", "  ")
+	return nil
+}
+
+// houseOf returns the number of the house containing an ecliptic longitude.
+// Gauquelin sectors are numbered clockwise, their cusps decrease in
+// longitude.
+func houseOf(c *ChartInfo, degreeUt float64) int {
+	n := len(c.cusps) - 1
+	for house := 1; house <= n; house++ {
+		next := house%n + 1
+		width := normalize(c.cusps[next] - c.cusps[house])
+		offset := normalize(degreeUt - c.cusps[house])
+		if c.Hsys == "G" {
+			width = normalize(c.cusps[house] - c.cusps[next])
+			offset = normalize(c.cusps[house] - degreeUt)
+		}
+		if offset < width {
+			return house
+		}
+	}
+	return 0
+}
+
+// isDiurnal tells whether the Sun is above the horizon, using its house
+func isDiurnal(c *ChartInfo) (bool, error) {
+	xx, err := calcUT(c.julday, C.SE_SUN, 0)
+	if err != nil {
+		return false, err
+	}
+
+	house := houseOf(c, c.derived(xx[0]))
+
+	// Gauquelin sectors are numbered clockwise from the ascendant, the
+	// first 18 are above the horizon
+	if c.Hsys == "G" {
+		return house <= 18, nil
+	}
+
+	return house >= 7, nil
+}
+
+// chartPoints returns the longitudes of the points of a chart by name: the
+// planets and lunar nodes, even when they are not displayed, the other
+// displayed bodies, the ascendant and other marks, and the house cusps
+func chartPoints(c *ChartInfo) (map[string]float64, error) {
+	points := make(map[string]float64)
+
+	for body := C.SE_SUN; body <= C.SE_TRUE_NODE; body++ {
+		xx, err := calcUT(c.julday, body, 0)
+		if err != nil {
+			return points, err
+		}
+		points[bnames[body]] = c.derived(xx[0])
+		if body == C.SE_MEAN_NODE {
+			points["MeanSouthNode"] = c.derived(normalize(xx[0] + 180))
+		}
+		if body == C.SE_TRUE_NODE {
+			points["TrueSouthNode"] = c.derived(normalize(xx[0] + 180))
+		}
+	}
+
+	for _, body := range c.Bodies {
+		points[body.XMLName.Local] = body.DegreeUt
+	}
+
+	for index := 0; index < C.SE_NASCMC; index++ {
+		points[anames[index]] = c.ascmc[index]
+	}
+
+	for house := 1; house < len(c.cusps); house++ {
+		points[hnames[house]] = c.cusps[house]
+	}
+
+	return points, nil
+}
+
+// ChartInfoHandler returns houses and planet positions for a location and time
+func ChartInfoHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	c, display, err := parseChartInfo(q, "")
 	if err != nil {
 		fmt.Printf("error: %v\n", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 
-	w.Header().Set("Content-Type", "text/xml; charset=UTF-8")
-	out = []byte("<?xml version='1.0' encoding='UTF-8'?>" + string(out))
-	w.Write(out)
+	if err := castChart(c, display); err != nil {
+		fmt.Printf("error: %v\n", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if c.UnknownTime != nil {
+		if err := addUnknownTime(c); err != nil {
+			fmt.Printf("error: %v\n", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	lots, err := queryLotFormulas(q)
+	if err != nil {
+		fmt.Printf("error: %v\n", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(lots) > 0 {
+		if err := addLots(c, lots); err != nil {
+			fmt.Printf("error: %v\n", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if wantsDignities(q) {
+		if err := addDignities(c, q); err != nil {
+			fmt.Printf("error: %v\n", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if q.Get("midpoints") == "1" {
+		addMidpoints(c, q)
+	}
+
+	if q.Get("antiscia") == "1" {
+		if err := addAntiscia(c, q); err != nil {
+			fmt.Printf("error: %v\n", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if q.Get("azalt") == "1" {
+		if err := addHorizon(c, q); err != nil {
+			fmt.Printf("error: %v\n", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if q.Get("nodes_apsides") != "" {
+		if err := addNodesApsides(c); err != nil {
+			fmt.Printf("error: %v\n", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if q.Get("pheno") == "1" {
+		if err := addPhenomena(c); err != nil {
+			fmt.Printf("error: %v\n", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if q.Get("horary") == "1" {
+		if err := addHorary(c, q); err != nil {
+			fmt.Printf("error: %v\n", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if q.Get("local_space") == "1" {
+		if err := addLocalSpace(c, q); err != nil {
+			fmt.Printf("error: %v\n", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if q.Get("planetary_hour") == "1" {
+		lat, lon := c.location()
+		ruler, err := hourRuler(c.julday, [3]float64{lon, lat, 0})
+		if err == errNoSunrise {
+			// There are no planetary hours in the polar day or night
+			ruler = "none"
+		} else if err != nil {
+			fmt.Printf("error: %v\n", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		c.PlanetaryHour = ruler
+	}
+
+	omitUnknownAngles(c)
+	writeXML(w, c)
 }
 
 // TransformHandler performs an XSLT transformation
@@ -487,6 +874,10 @@ func TransformHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func sweSetEphePath(path string) {
+	// The swisseph settings are thread local, other threads read the path
+	// from the environment when they first need it
+	os.Setenv("SE_EPHE_PATH", path)
+
 	cpath := C.CString(path)
 	defer C.free(unsafe.Pointer(cpath))
 	C.swe_set_ephe_path(cpath)
@@ -507,6 +898,20 @@ func main() {
 	http.HandleFunc("/chartinfo", ChartInfoHandler)
 	http.HandleFunc("/transform.py", TransformHandler)
 	http.HandleFunc("/transform", TransformHandler)
+	http.HandleFunc("/eclipses", EclipsesHandler)
+	http.HandleFunc("/almanac", AlmanacHandler)
+	http.HandleFunc("/heliacal", HeliacalHandler)
+	http.HandleFunc("/planetaryhours", PlanetaryHoursHandler)
+	http.HandleFunc("/vargas", VargasHandler)
+	http.HandleFunc("/dashas", DashasHandler)
+	http.HandleFunc("/panchanga", PanchangaHandler)
+	http.HandleFunc("/timelords", TimeLordsHandler)
+	http.HandleFunc("/directions", DirectionsHandler)
+	http.HandleFunc("/rectification", RectificationHandler)
+	http.HandleFunc("/astrocartography", AstrocartographyHandler)
+	http.HandleFunc("/occultations", OccultationsHandler)
+	http.HandleFunc("/voidofcourse", VoidOfCourseHandler)
+	http.HandleFunc("/elections", ElectionsHandler)
 
 	port := os.Getenv("PORT")
 
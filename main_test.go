@@ -427,3 +427,90 @@ func TestChartInfoHandler(t *testing.T) {
 // 	}
 // 	wg.Wait()
 // }
+
+func Test_signOf(t *testing.T) {
+	tests := []struct {
+		name       string
+		degreeUt   float64
+		wantSign   int
+		wantDegree float64
+	}{
+		{name: "Aries", degreeUt: 10, wantSign: 0, wantDegree: 10},
+		{name: "Cusp of Libra", degreeUt: 180, wantSign: 6, wantDegree: 0},
+		{name: "Pisces", degreeUt: 359, wantSign: 11, wantDegree: 29},
+		{name: "Negative angle", degreeUt: -15, wantSign: 11, wantDegree: 15},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sign, degree := signOf(tt.degreeUt)
+			if sign != tt.wantSign || degree != tt.wantDegree {
+				t.Errorf("signOf() = %v, %v, want %v, %v", sign, degree, tt.wantSign, tt.wantDegree)
+			}
+		})
+	}
+}
+
+func Test_angleDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		a    float64
+		b    float64
+		want float64
+	}{
+		{name: "Simple difference", a: 30, b: 10, want: 20},
+		{name: "Reversed order", a: 10, b: 30, want: 20},
+		{name: "Across 0", a: 355, b: 5, want: 10},
+		{name: "Opposition", a: 90, b: 270, want: 180},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := angleDiff(tt.a, tt.b); got != tt.want {
+				t.Errorf("angleDiff() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_houseOf(t *testing.T) {
+	c := &ChartInfo{cusps: []float64{0, 350, 20, 50, 80, 110, 140, 170, 200, 230, 260, 290, 320}}
+	tests := []struct {
+		name     string
+		degreeUt float64
+		want     int
+	}{
+		{name: "First house across 0", degreeUt: 5, want: 1},
+		{name: "On a cusp", degreeUt: 50, want: 3},
+		{name: "Twelfth house", degreeUt: 349, want: 12},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := houseOf(c, tt.degreeUt); got != tt.want {
+				t.Errorf("houseOf() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_houseOfGauquelin(t *testing.T) {
+	c := &ChartInfo{Hsys: "G", cusps: make([]float64, 37)}
+	for i := 1; i <= 36; i++ {
+		c.cusps[i] = normalize(100 - float64(i-1)*10)
+	}
+	tests := []struct {
+		name     string
+		degreeUt float64
+		want     int
+	}{
+		{name: "First sector", degreeUt: 95, want: 1},
+		{name: "On a cusp", degreeUt: 90, want: 2},
+		{name: "Across 0", degreeUt: 355, want: 11},
+		{name: "Last sector", degreeUt: 105, want: 36},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := houseOf(c, tt.degreeUt); got != tt.want {
+				t.Errorf("houseOf() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"math"
+	"net/url"
+	"sort"
+)
+
+// Midpoints is the optional section with the midpoints of the displayed
+// bodies and angles, their occupants and the dials
+type Midpoints struct {
+	Midpoints []Midpoint `xml:"Midpoint"`
+	Pictures  []Picture  `xml:"Picture"`
+	Trees     []Tree     `xml:"trees>Tree"`
+	Dials     []Dial     `xml:"dials>Dial"`
+	Orb       float64    `xml:"orb,attr"`
+	Dial      float64    `xml:"dial,attr"`
+}
+
+// Midpoint is the point halfway between two bodies on the shorter arc
+type Midpoint struct {
+	Body1     string     `xml:"body1,attr"`
+	Body2     string     `xml:"body2,attr"`
+	SignName  string     `xml:"sign_name,attr"`
+	DegreeUt  float64    `xml:"degree_ut,attr"`
+	Degree    float64    `xml:"degree,attr"`
+	Sign      int        `xml:"sign,attr"`
+	Occupants []Occupant `xml:"Occupant"`
+}
+
+// Occupant is a body standing on a midpoint within the orb, on the dial
+type Occupant struct {
+	Body string  `xml:"body,attr"`
+	Orb  float64 `xml:"orb,attr"`
+}
+
+// Tree lists the midpoints occupied by a body, the closest first
+type Tree struct {
+	Body     string   `xml:"body,attr"`
+	Branches []Branch `xml:"Branch"`
+}
+
+// Branch is a midpoint, like Sun/Moon, in a midpoint tree
+type Branch struct {
+	Midpoint string  `xml:"midpoint,attr"`
+	Orb      float64 `xml:"orb,attr"`
+}
+
+// Dial lists the bodies and the midpoints sorted by their position on a
+// dial of the given size
+type Dial struct {
+	Size   float64     `xml:"size,attr"`
+	Points []DialPoint `xml:"Point"`
+}
+
+// DialPoint is a body or a midpoint, like Sun/Moon, on a dial
+type DialPoint struct {
+	Name     string  `xml:"name,attr"`
+	Position float64 `xml:"position,attr"`
+}
+
+// Picture is a planetary picture A+B-C falling on a body
+type Picture struct {
+	Formula  string  `xml:"formula,attr"`
+	DegreeUt float64 `xml:"degree_ut,attr"`
+	Body     string  `xml:"body,attr"`
+	Orb      float64 `xml:"orb,attr"`
+}
+
+// Sizes of the dials used to sort the midpoints
+var dialSizes = []float64{90, 45, 22.5}
+
+// A named ecliptic longitude
+type point struct {
+	name     string
+	degreeUt float64
+}
+
+// midpoint returns the midpoint of two longitudes on the shorter arc
+func midpoint(a, b float64) float64 {
+	d := normalize(b - a)
+	if d > 180 {
+		d -= 360
+	}
+	return normalize(a + d/2)
+}
+
+// dialDiff returns the shortest distance between two angles projected on a
+// dial of the given size
+func dialDiff(a, b, size float64) float64 {
+	d := math.Mod(normalize(a-b), size)
+	return math.Min(d, size-d)
+}
+
+// Returns the displayed bodies, the ascendant and the MC of a chart. The
+// Earth is left out as it has no geocentric position.
+func midpointPoints(c *ChartInfo) []point {
+	var points []point
+	for _, b := range c.Bodies {
+		if b.XMLName.Local == "Earth" {
+			continue
+		}
+		points = append(points, point{b.XMLName.Local, b.DegreeUt})
+	}
+	// The angles of a chart cast for an unknown time are not known
+	if c.UnknownTime != nil {
+		return points
+	}
+	for _, a := range c.AscMCs {
+		if a.XMLName.Local == "Ascendant" || a.XMLName.Local == "MC" {
+			points = append(points, point{a.XMLName.Local, a.DegreeUt})
+		}
+	}
+	return points
+}
+
+// midpoints computes every pairwise midpoint and the points occupying it
+// within orb on a dial
+func midpoints(points []point, orb, dial float64) []Midpoint {
+	var mps []Midpoint
+
+	for i, p1 := range points {
+		for _, p2 := range points[i+1:] {
+			degreeUt := midpoint(p1.degreeUt, p2.degreeUt)
+			sign, degree := signOf(degreeUt)
+			mp := Midpoint{
+				Body1:    p1.name,
+				Body2:    p2.name,
+				SignName: snames[sign],
+				DegreeUt: degreeUt,
+				Degree:   degree,
+				Sign:     sign,
+			}
+
+			for _, p := range points {
+				if p.name == p1.name || p.name == p2.name {
+					continue
+				}
+				if d := dialDiff(p.degreeUt, degreeUt, dial); d <= orb {
+					mp.Occupants = append(mp.Occupants, Occupant{Body: p.name, Orb: d})
+				}
+			}
+
+			mps = append(mps, mp)
+		}
+	}
+
+	return mps
+}
+
+// trees gathers the occupants of the midpoints by body
+func trees(points []point, mps []Midpoint) []Tree {
+	var ts []Tree
+
+	for _, p := range points {
+		t := Tree{Body: p.name}
+		for _, mp := range mps {
+			for _, o := range mp.Occupants {
+				if o.Body == p.name {
+					t.Branches = append(t.Branches, Branch{mp.Body1 + "/" + mp.Body2, o.Orb})
+				}
+			}
+		}
+		sort.SliceStable(t.Branches, func(i, j int) bool {
+			return t.Branches[i].Orb < t.Branches[j].Orb
+		})
+		ts = append(ts, t)
+	}
+
+	return ts
+}
+
+// dials sorts the points and the midpoints on the 90°, 45° and 22.5° dials
+func dials(points []point, mps []Midpoint) []Dial {
+	var ds []Dial
+
+	for _, size := range dialSizes {
+		d := Dial{Size: size}
+		for _, p := range points {
+			d.Points = append(d.Points, DialPoint{p.name, math.Mod(p.degreeUt, size)})
+		}
+		for _, mp := range mps {
+			d.Points = append(d.Points, DialPoint{mp.Body1 + "/" + mp.Body2, math.Mod(mp.DegreeUt, size)})
+		}
+		sort.SliceStable(d.Points, func(i, j int) bool {
+			return d.Points[i].Position < d.Points[j].Position
+		})
+		ds = append(ds, d)
+	}
+
+	return ds
+}
+
+// pictures finds the planetary pictures A+B-C landing on a fourth point
+// within orb on a dial
+func pictures(points []point, orb, dial float64) []Picture {
+	var ps []Picture
+
+	for i, a := range points {
+		for _, b := range points[i+1:] {
+			for _, c := range points {
+				if c.name == a.name || c.name == b.name {
+					continue
+				}
+				degreeUt := normalize(a.degreeUt + b.degreeUt - c.degreeUt)
+				for _, p := range points {
+					if p.name == a.name || p.name == b.name || p.name == c.name {
+						continue
+					}
+					if d := dialDiff(p.degreeUt, degreeUt, dial); d <= orb {
+						ps = append(ps, Picture{
+							Formula:  a.name + "+" + b.name + "-" + c.name,
+							DegreeUt: degreeUt,
+							Body:     p.name,
+							Orb:      d,
+						})
+					}
+				}
+			}
+		}
+	}
+
+	return ps
+}
+
+// addMidpoints adds the midpoints section to a chart. The orb and the dial
+// used for the occupants come from midpoint_orb and midpoint_dial,
+// pictures=1 enables the planetary pictures within picture_orb.
+func addMidpoints(c *ChartInfo, q url.Values) {
+	m := &Midpoints{
+		Orb:  queryFloat(q, "midpoint_orb", 1.5),
+		Dial: queryFloat(q, "midpoint_dial", 90),
+	}
+	if m.Dial <= 0 || m.Dial > 360 {
+		m.Dial = 90
+	}
+
+	points := midpointPoints(c)
+	m.Midpoints = midpoints(points, m.Orb, m.Dial)
+	m.Trees = trees(points, m.Midpoints)
+	m.Dials = dials(points, m.Midpoints)
+	if q.Get("pictures") == "1" {
+		m.Pictures = pictures(points, queryFloat(q, "picture_orb", 1), m.Dial)
+	}
+
+	c.Midpoints = m
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func Test_midpoint(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b float64
+		want float64
+	}{
+		{name: "Same half", a: 10, b: 50, want: 30},
+		{name: "Reversed", a: 50, b: 10, want: 30},
+		{name: "Across Aries", a: 350, b: 20, want: 5},
+		{name: "Opposition", a: 0, b: 180, want: 90},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := midpoint(tt.a, tt.b); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("midpoint() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_dialDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b float64
+		size float64
+		want float64
+	}{
+		{name: "Square on the 90° dial", a: 100, b: 10, size: 90, want: 0},
+		{name: "Semi-square on the 45° dial", a: 55, b: 10, size: 45, want: 0},
+		{name: "Wrapping", a: 89, b: 1, size: 90, want: 2},
+		{name: "Sesquiquadrate on the 22.5° dial", a: 145, b: 10, size: 22.5, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := dialDiff(tt.a, tt.b, tt.size); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("dialDiff() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_pictures(t *testing.T) {
+	points := []point{{"Sun", 10}, {"Moon", 40}, {"Mars", 20}, {"Venus", 120}}
+
+	ps := pictures(points, 1, 90)
+	found := false
+	for _, p := range ps {
+		if p.Formula == "Sun+Moon-Mars" && p.Body == "Venus" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("pictures() = %v, want Sun+Moon-Mars on Venus", ps)
+	}
+}
+
+func TestChartInfoHandlerMidpoints(t *testing.T) {
+	sweSetEphePath("swe")
+	defer sweClose()
+
+	req, err := http.NewRequest("GET", "/chartinfo?year=2019&month=2&day=18&time=16.083334&lat=48&lon=2&display=0,1,2,3,4,5,6,7,8,9&midpoints=1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(ChartInfoHandler)
+	handler.ServeHTTP(rr, req)
+
+	for _, want := range []string{
+		`<midpoints orb="1.5" dial="90">`,
+		`<Branch midpoint="Moon/Mercury"`,
+		`<Dial size="22.5">`,
+	} {
+		if !strings.Contains(rr.Body.String(), want) {
+			t.Errorf("handler returned wrong xml: got %v want %v", rr.Body.String(), want)
+		}
+	}
+	if strings.Contains(rr.Body.String(), "<Picture") {
+		t.Errorf("handler returned pictures without pictures=1")
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/xml"
+	"errors"
+	"net/url"
+)
+
+/*
+#include "swephexp.h"
+*/
+import "C"
+
+// NodesApsides lists the nodes and the apsides of the planets
+type NodesApsides struct {
+	Method  string         `xml:"method,attr"`
+	Planets []PlanetNodAps `xml:"Planet"`
+}
+
+// PlanetNodAps holds the ascending and descending nodes, the perihelion and
+// the aphelion of a planet
+type PlanetNodAps struct {
+	Name   string        `xml:"name,attr"`
+	Points []NodApsPoint `xml:",any"`
+}
+
+// NodApsPoint is a node or an apsis of a planet
+type NodApsPoint struct {
+	XMLName  xml.Name
+	SignName string  `xml:"sign_name,attr"`
+	DegreeUt float64 `xml:"degree_ut,attr"`
+	Degree   float64 `xml:"degree,attr"`
+	Sign     int     `xml:"sign,attr"`
+	Dist     float64 `xml:"dist,attr"`
+}
+
+// The planets having nodes and apsides, and the names of these points
+var nodApsPlanets = []int{C.SE_MERCURY, C.SE_VENUS, C.SE_MARS, C.SE_JUPITER,
+	C.SE_SATURN, C.SE_URANUS, C.SE_NEPTUNE, C.SE_PLUTO}
+var nodApsNames = []string{"AscendingNode", "DescendingNode", "Perihelion", "Aphelion"}
+
+// The nodes and the apsides follow the south nodes in the body numbers,
+// four for each planet, named like MercuryAscendingNode
+const firstNodApsID = C.SE_NPLANETS + 2
+
+func init() {
+	for _, ipl := range nodApsPlanets {
+		for _, name := range nodApsNames {
+			bnames = append(bnames, bnames[ipl]+name)
+		}
+	}
+}
+
+// Methods of swe_nod_aps_ut by name
+var nodApsMethods = map[string]int{
+	"mean":       C.SE_NODBIT_MEAN,
+	"osculating": C.SE_NODBIT_OSCU,
+}
+
+// parseNodAps reads nodes_apsides=mean or osculating, the method of the
+// nodes and the apsides, mean by default
+func parseNodAps(q url.Values, prefix string, c *ChartInfo) error {
+	name := q.Get(prefix + "nodes_apsides")
+	if name == "" {
+		return nil
+	}
+	method, ok := nodApsMethods[name]
+	if !ok {
+		return errors.New("unknown nodes and apsides method: " + name)
+	}
+	c.nodApsMethod = method
+	return nil
+}
+
+// bodyPosition computes the position of a body of a chart by number,
+// including the south nodes and the nodes and apsides of the planets
+func (c *ChartInfo) bodyPosition(jd float64, id int, iflag int) ([6]float64, error) {
+	switch {
+	case id == 23 || id == 24:
+		xx, err := calcUT(jd, id-23+C.SE_MEAN_NODE, iflag)
+		xx[0], xx[1] = normalize(xx[0]+180), -xx[1]
+		return xx, err
+
+	case id >= firstNodApsID:
+		method := c.nodApsMethod
+		if method == 0 {
+			method = C.SE_NODBIT_MEAN
+		}
+		n := id - firstNodApsID
+		xx, err := nodApsUT(jd, nodApsPlanets[n/len(nodApsNames)], iflag, method)
+		return xx[n%len(nodApsNames)], err
+	}
+
+	return calcUT(jd, id, iflag)
+}
+
+// addNodesApsides adds the nodes and the apsides of the planets to a chart
+func addNodesApsides(c *ChartInfo) error {
+	na := &NodesApsides{Method: "mean"}
+	if c.nodApsMethod == C.SE_NODBIT_OSCU {
+		na.Method = "osculating"
+	}
+
+	for p, ipl := range nodApsPlanets {
+		planet := PlanetNodAps{Name: bnames[ipl]}
+		for k, name := range nodApsNames {
+			xx, err := c.bodyPosition(c.julday, firstNodApsID+p*len(nodApsNames)+k, 0)
+			if err != nil {
+				return err
+			}
+			degreeUt := c.derived(xx[0])
+			sign, degree := signOf(degreeUt)
+			planet.Points = append(planet.Points, NodApsPoint{XMLName: xml.Name{Local: name},
+				SignName: snames[sign], DegreeUt: degreeUt, Degree: degree, Sign: sign, Dist: xx[2]})
+		}
+		na.Planets = append(na.Planets, planet)
+	}
+
+	c.NodesApsides = na
+	return nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func Test_nodApsNames(t *testing.T) {
+	tests := []struct {
+		id   int
+		want string
+	}{
+		{24, "TrueSouthNode"},
+		{25, "MercuryAscendingNode"},
+		{28, "MercuryAphelion"},
+		{31, "VenusPerihelion"},
+		{53, "PlutoAscendingNode"},
+		{56, "PlutoAphelion"},
+	}
+	for _, tt := range tests {
+		if bnames[tt.id] != tt.want {
+			t.Errorf("bnames[%d] = %v, want %v", tt.id, bnames[tt.id], tt.want)
+		}
+	}
+	if len(bnames) != 57 {
+		t.Errorf("len(bnames) = %d, want 57", len(bnames))
+	}
+}
+
+func Test_parseNodAps(t *testing.T) {
+	tests := []struct {
+		query   string
+		want    int
+		wantErr bool
+	}{
+		{query: "", want: 0},
+		{query: "nodes_apsides=mean", want: 1},
+		{query: "nodes_apsides=osculating", want: 2},
+		{query: "nodes_apsides=keplerian", wantErr: true},
+	}
+	for _, tt := range tests {
+		q, _ := url.ParseQuery(tt.query)
+		c := &ChartInfo{}
+		err := parseNodAps(q, "", c)
+		if (err != nil) != tt.wantErr || c.nodApsMethod != tt.want {
+			t.Errorf("parseNodAps(%q) = %v, %v, want %v", tt.query, c.nodApsMethod, err, tt.want)
+		}
+	}
+}
+
+func Test_bodyPosition(t *testing.T) {
+	sweSetEphePath("swe")
+	defer sweClose()
+
+	jd := julday(1980, 5, 17, 12)
+	c := &ChartInfo{nodApsMethod: 2}
+
+	south, err := c.bodyPosition(jd, 23, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	north, _ := calcUT(jd, 10, 0)
+	if math.Abs(angleDiff(south[0], north[0])-180) > 1e-9 {
+		t.Errorf("bodyPosition() south node = %v, north node = %v", south[0], north[0])
+	}
+
+	perihelion, err := c.bodyPosition(jd, 43, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	xx, _ := nodApsUT(jd, 6, 0, 2)
+	if perihelion != xx[2] {
+		t.Errorf("bodyPosition() Saturn perihelion = %v, want %v", perihelion, xx[2])
+	}
+}
+
+func TestChartInfoHandlerNodesApsides(t *testing.T) {
+	sweSetEphePath("swe")
+	defer sweClose()
+
+	req := httptest.NewRequest("GET", "/chartinfo?year=1980&month=5&day=17&time=12&lat=48.85&lon=2.35&display=0,25,28&nodes_apsides=osculating", nil)
+	w := httptest.NewRecorder()
+	ChartInfoHandler(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("ChartInfoHandler() status = %v", w.Code)
+	}
+
+	for _, s := range []string{
+		`<MercuryAscendingNode sign_name="Taurus" dist="0" degree_ut="54.67`,
+		`<MercuryAphelion sign_name="Taurus" dist="0" degree_ut="41.05`,
+		`<nodes_apsides method="osculating">`,
+		`<Planet name="Pluto">`,
+		`<Perihelion sign_name="Aries" degree_ut="8.71`,
+	} {
+		if !strings.Contains(w.Body.String(), s) {
+			t.Errorf("ChartInfoHandler() output missing %s", s)
+		}
+	}
+
+	req = httptest.NewRequest("GET", "/chartinfo?display=25&nodes_apsides=keplerian", nil)
+	w = httptest.NewRecorder()
+	ChartInfoHandler(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("ChartInfoHandler() status = %v for an unknown method", w.Code)
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"unsafe"
+)
+
+/*
+#include "swephexp.h"
+*/
+import "C"
+
+// Occultations is the root node of the lunar occultation search output
+type Occultations struct {
+	XMLName      xml.Name      `xml:"occultations"`
+	Occultations []Occultation `xml:"Occultation"`
+	Body         string        `xml:"body,attr"`
+	Start        string        `xml:"start,attr"`
+	End          string        `xml:"end,attr"`
+	Lat          float64       `xml:"lat,attr,omitempty"`
+	Lon          float64       `xml:"lon,attr,omitempty"`
+}
+
+// Occultation is the Moon passing in front of a planet or a star, as seen
+// from the whole earth, with the circumstances for a location if any
+type Occultation struct {
+	Type       string        `xml:"type,attr"`
+	Central    bool          `xml:"central,attr,omitempty"`
+	Max        string        `xml:"max,attr"`
+	Begin      string        `xml:"begin,attr,omitempty"`
+	End        string        `xml:"end,attr,omitempty"`
+	TotalBegin string        `xml:"total_begin,attr,omitempty"`
+	TotalEnd   string        `xml:"total_end,attr,omitempty"`
+	SignName   string        `xml:"sign_name,attr"`
+	DegreeUt   float64       `xml:"degree_ut,attr"`
+	Degree     float64       `xml:"degree,attr"`
+	Sign       int           `xml:"sign,attr"`
+	Local      *LocalEclipse `xml:"local,omitempty"`
+}
+
+// occultedBody is a planet, or a fixed star when star is not empty
+type occultedBody struct {
+	ipl  int
+	star string
+}
+
+// cstar returns the star name in a buffer swisseph can write the full name
+// of the star to, or nil for a planet
+func (b occultedBody) cstar() (*C.char, []byte) {
+	if b.star == "" {
+		return nil, nil
+	}
+	buf := make([]byte, 2*C.SE_MAX_STNAME)
+	copy(buf, b.star)
+	return (*C.char)(unsafe.Pointer(&buf[0])), buf
+}
+
+// name returns the name of the occulted body
+func (b occultedBody) name() string {
+	if b.star == "" {
+		return bnames[b.ipl]
+	}
+	return strings.Split(b.star, ",")[0]
+}
+
+// lunarOccultations lists the occultations of a body by the Moon happening
+// between two julian days
+func lunarOccultations(b occultedBody, start, end float64, geopos *[3]float64) ([]Occultation, error) {
+	var occultations []Occultation
+	var tret [10]C.double
+	serr := make([]byte, 256)
+	star, _ := b.cstar()
+
+	for t := start; ; t = float64(tret[0]) + 1 {
+		mu.Lock()
+		ret := C.swe_lun_occult_when_glob(C.double(t), C.int32(b.ipl), star, C.SEFLG_SWIEPH, 0, &tret[0], 0, (*C.char)(unsafe.Pointer(&serr[0])))
+		mu.Unlock()
+
+		if ret < 0 {
+			return occultations, sweError(serr)
+		}
+		if float64(tret[0]) > end {
+			break
+		}
+
+		o := Occultation{
+			Type:       eclipseType(int(ret)),
+			Central:    int(ret)&C.SE_ECL_CENTRAL != 0,
+			Max:        utDate(float64(tret[0])),
+			Begin:      optDate(tret[2]),
+			End:        optDate(tret[3]),
+			TotalBegin: optDate(tret[4]),
+			TotalEnd:   optDate(tret[5]),
+		}
+
+		xx, err := calcUT(float64(tret[0]), C.SE_MOON, 0)
+		if err != nil {
+			return occultations, err
+		}
+		o.DegreeUt = xx[0]
+		o.Sign, o.Degree = signOf(o.DegreeUt)
+		o.SignName = snames[o.Sign]
+
+		if geopos != nil {
+			if o.Local, err = localOccultation(b, float64(tret[0]), geopos); err != nil {
+				return occultations, err
+			}
+		}
+
+		occultations = append(occultations, o)
+	}
+
+	return occultations, nil
+}
+
+// localOccultation returns the local circumstances of the occultation whose
+// global maximum is at jd
+func localOccultation(b occultedBody, jd float64, geopos *[3]float64) (*LocalEclipse, error) {
+	var tret [10]C.double
+	var attr [20]C.double
+	serr := make([]byte, 256)
+	star, _ := b.cstar()
+
+	mu.Lock()
+	ret := C.swe_lun_occult_when_loc(C.double(jd-1), C.int32(b.ipl), star, C.SEFLG_SWIEPH, (*C.double)(unsafe.Pointer(&geopos[0])), &tret[0], &attr[0], 0, (*C.char)(unsafe.Pointer(&serr[0])))
+	mu.Unlock()
+
+	if ret < 0 {
+		return nil, sweError(serr)
+	}
+
+	// The search skips occultations that can't be seen from this location
+	if math.Abs(float64(tret[0])-jd) > 1 {
+		return &LocalEclipse{}, nil
+	}
+
+	return &LocalEclipse{
+		Visible:       int(ret)&C.SE_ECL_VISIBLE != 0,
+		MaxVisible:    int(ret)&C.SE_ECL_MAX_VISIBLE != 0,
+		Max:           optDate(tret[0]),
+		FirstContact:  optDate(tret[1]),
+		SecondContact: optDate(tret[2]),
+		ThirdContact:  optDate(tret[3]),
+		FourthContact: optDate(tret[4]),
+		Azimuth:       float64(attr[4]),
+		Altitude:      float64(attr[6]),
+	}, nil
+}
+
+// OccultationsHandler lists the occultations by the Moon of the planet given
+// by body, or of the fixed star given by star, over a time range. The local
+// circumstances are given for a location with lat and lon.
+func OccultationsHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	start, end := queryRange(q)
+	geopos := queryGeopos(q)
+
+	b := occultedBody{ipl: int(queryInt(q, "body", C.SE_VENUS)), star: q.Get("star")}
+	if b.star == "" && (b.ipl == C.SE_SUN || b.ipl == C.SE_MOON || b.ipl < 0 || b.ipl > C.SE_PLUTO) {
+		err := errors.New("the Moon can only occult a planet or a star")
+		fmt.Printf("error: %v\n", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	o := &Occultations{Body: b.name(), Start: utDate(start), End: utDate(end)}
+	if geopos != nil {
+		o.Lon, o.Lat = geopos[0], geopos[1]
+	}
+
+	var err error
+	if o.Occultations, err = lunarOccultations(b, start, end, geopos); err != nil {
+		fmt.Printf("error: %v\n", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writeXML(w, o)
+}
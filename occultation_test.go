@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOccultationsHandler(t *testing.T) {
+	sweSetEphePath("swe")
+	defer sweClose()
+
+	req, err := http.NewRequest("GET", "/occultations?year=2023&body=3&lat=48.85&lon=2.35", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(OccultationsHandler)
+
+	handler.ServeHTTP(rr, req)
+
+	var got Occultations
+	if err := xml.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Body != "Venus" || len(got.Occultations) == 0 {
+		t.Fatalf("handler returned %v occultations of %v, want some of Venus", len(got.Occultations), got.Body)
+	}
+
+	var paris *Occultation
+	for i, o := range got.Occultations {
+		if o.Max[:10] == "2023-11-09" {
+			paris = &got.Occultations[i]
+		}
+	}
+	if paris == nil {
+		t.Fatalf("handler returned no occultation of Venus on 2023-11-09")
+	}
+	if paris.Type != "Total" || paris.SignName != "Libra" {
+		t.Errorf("handler returned wrong occultation: got %v %v want Total Libra", paris.Type, paris.SignName)
+	}
+	if paris.Local == nil || !paris.Local.Visible || paris.Local.FirstContact[:13] != "2023-11-09T09" {
+		t.Errorf("handler returned occultation not visible from Paris: %+v", paris.Local)
+	}
+}
+
+func TestOccultationsHandlerErrors(t *testing.T) {
+	sweSetEphePath("swe")
+	defer sweClose()
+
+	tests := []string{
+		"/occultations?year=2024&body=0",
+		"/occultations?year=2024&body=1",
+		"/occultations?year=2024&star=Sirius",
+	}
+
+	for _, url := range tests {
+		req, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(OccultationsHandler).ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%v returned status %v, want %v", url, rr.Code, http.StatusBadRequest)
+		}
+	}
+}
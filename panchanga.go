@@ -0,0 +1,281 @@
+package main
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+/*
+#include "swephexp.h"
+*/
+import "C"
+
+// Panchanga is the root node of the panchanga output. The five limbs are
+// those in effect at sunrise, each with its end and its successor.
+type Panchanga struct {
+	XMLName       xml.Name      `xml:"panchanga"`
+	Tithi         PanchangaLimb `xml:"Tithi"`
+	Vara          PanchangaLimb `xml:"Vara"`
+	Nakshatra     PanchangaLimb `xml:"Nakshatra"`
+	Yoga          PanchangaLimb `xml:"Yoga"`
+	Karana        PanchangaLimb `xml:"Karana"`
+	Flags         []string      `xml:"Flag"`
+	Sunrise       string        `xml:"sunrise,attr"`
+	NextSunrise   string        `xml:"next_sunrise,attr"`
+	Ayanamsa      string        `xml:"ayanamsa,attr"`
+	AyanamsaValue float64       `xml:"ayanamsa_value,attr"`
+	Lat           float64       `xml:"lat,attr"`
+	Lon           float64       `xml:"lon,attr"`
+}
+
+// PanchangaLimb is a tithi, a vara, a nakshatra, a yoga or a karana
+type PanchangaLimb struct {
+	Name   string `xml:"name,attr"`
+	Number int    `xml:"number,attr"`
+	Paksha string `xml:"paksha,attr,omitempty"`
+	Pada   int    `xml:"pada,attr,omitempty"`
+	Lord   string `xml:"lord,attr,omitempty"`
+	End    string `xml:"end,attr"`
+	Next   string `xml:"next,attr"`
+}
+
+// Names of the tithis of a paksha, the last one being Purnima in the
+// waxing fortnight and Amavasya in the waning one
+var tithis = []string{"Pratipada", "Dvitiya", "Tritiya", "Chaturthi",
+	"Panchami", "Shashthi", "Saptami", "Ashtami", "Navami", "Dashami",
+	"Ekadashi", "Dvadashi", "Trayodashi", "Chaturdashi", "Purnima"}
+
+// Names of the days of the week, starting with Sunday
+var varas = []string{"Ravivara", "Somavara", "Mangalavara", "Budhavara",
+	"Guruvara", "Shukravara", "Shanivara"}
+
+// Names of the 27 yogas
+var yogas = []string{"Vishkambha", "Priti", "Ayushman", "Saubhagya",
+	"Shobhana", "Atiganda", "Sukarma", "Dhriti", "Shula", "Ganda", "Vriddhi",
+	"Dhruva", "Vyaghata", "Harshana", "Vajra", "Siddhi", "Vyatipata",
+	"Variyan", "Parigha", "Shiva", "Siddha", "Sadhya", "Shubha", "Shukla",
+	"Brahma", "Indra", "Vaidhriti"}
+
+// Names of the seven movable karanas
+var karanas = []string{"Bava", "Balava", "Kaulava", "Taitila", "Garaja",
+	"Vanija", "Vishti"}
+
+// tithiName returns the name and the paksha of a tithi numbered from 0 to 29
+func tithiName(n int) (string, string) {
+	if n < 15 {
+		return tithis[n], "Shukla"
+	}
+	if n == 29 {
+		return "Amavasya", "Krishna"
+	}
+	return tithis[n-15], "Krishna"
+}
+
+// karanaName returns the name of a karana numbered from 0 to 59. The four
+// fixed karanas take the first and the last three halves of the month.
+func karanaName(n int) string {
+	switch n {
+	case 0:
+		return "Kimstughna"
+	case 57:
+		return "Shakuni"
+	case 58:
+		return "Chatushpada"
+	case 59:
+		return "Naga"
+	}
+	return karanas[(n-1)%len(karanas)]
+}
+
+// sunMoon returns the tropical longitudes of the Sun and the Moon
+func sunMoon(jd float64) (float64, float64, error) {
+	sun, err := calcUT(jd, C.SE_SUN, 0)
+	if err != nil {
+		return 0, 0, err
+	}
+	moon, err := calcUT(jd, C.SE_MOON, 0)
+	if err != nil {
+		return 0, 0, err
+	}
+	return sun[0], moon[0], nil
+}
+
+// limbIndex numbers the tithi, karana, nakshatra or yoga in effect at jd
+type limbIndex func(jd float64) (int, error)
+
+// panchangaIndexes returns the functions numbering the tithi, the karana,
+// the nakshatra and the yoga for an ayanamsa
+func panchangaIndexes(ayanamsa float64) (tithi, karana, nakshatra, yoga limbIndex) {
+	elongation := func(jd float64, part float64) (int, error) {
+		sun, moon, err := sunMoon(jd)
+		return int(normalize(moon-sun) / part), err
+	}
+	tithi = func(jd float64) (int, error) { return elongation(jd, 12) }
+	karana = func(jd float64) (int, error) { return elongation(jd, 6) }
+	nakshatra = func(jd float64) (int, error) {
+		_, moon, err := sunMoon(jd)
+		return int(normalize(moon-ayanamsa) / nakshatraSpan), err
+	}
+	yoga = func(jd float64) (int, error) {
+		sun, moon, err := sunMoon(jd)
+		return int(normalize(sun+moon-2*ayanamsa) / nakshatraSpan), err
+	}
+	return
+}
+
+// limbEnd finds when the limb in effect at jd ends, stepping by hours and
+// refining by bisection to the second
+func limbEnd(jd float64, index limbIndex) (float64, error) {
+	n, err := index(jd)
+	if err != nil {
+		return 0, err
+	}
+
+	step := 1.0 / 24
+	before, after := jd, jd
+	for {
+		after += step
+		if after > jd+3 {
+			return 0, errors.New("no end found for the panchanga element")
+		}
+		m, err := index(after)
+		if err != nil {
+			return 0, err
+		}
+		if m != n {
+			break
+		}
+		before = after
+	}
+
+	for after-before > 1.0/86400 {
+		mid := (before + after) / 2
+		m, err := index(mid)
+		if err != nil {
+			return 0, err
+		}
+		if m == n {
+			before = mid
+		} else {
+			after = mid
+		}
+	}
+
+	return after, nil
+}
+
+// panchanga computes the five limbs in effect at sunrise
+func panchanga(rise, next float64, lon, ayanamsa float64) (*Panchanga, error) {
+	p := &Panchanga{Sunrise: utDate(rise), NextSunrise: utDate(next)}
+	tithi, karana, nakshatra, yoga := panchangaIndexes(ayanamsa)
+
+	n, err := tithi(rise)
+	if err != nil {
+		return nil, err
+	}
+	end, err := limbEnd(rise, tithi)
+	if err != nil {
+		return nil, err
+	}
+	name, paksha := tithiName(n)
+	nextName, _ := tithiName((n + 1) % 30)
+	p.Tithi = PanchangaLimb{Name: name, Number: n + 1, Paksha: paksha, End: utDate(end), Next: nextName}
+
+	day := weekday(rise, lon)
+	p.Vara = PanchangaLimb{Name: varas[day], Number: day + 1, Lord: dayRulers[day], End: utDate(next), Next: varas[(day+1)%7]}
+
+	n, err = nakshatra(rise)
+	if err != nil {
+		return nil, err
+	}
+	end, err = limbEnd(rise, nakshatra)
+	if err != nil {
+		return nil, err
+	}
+	_, moon, err := sunMoon(rise)
+	if err != nil {
+		return nil, err
+	}
+	_, pada, lord := nakshatraOf(moon - ayanamsa)
+	p.Nakshatra = PanchangaLimb{Name: nakshatras[n], Number: n + 1, Pada: pada, Lord: lord, End: utDate(end), Next: nakshatras[(n+1)%27]}
+
+	n, err = yoga(rise)
+	if err != nil {
+		return nil, err
+	}
+	end, err = limbEnd(rise, yoga)
+	if err != nil {
+		return nil, err
+	}
+	p.Yoga = PanchangaLimb{Name: yogas[n], Number: n + 1, End: utDate(end), Next: yogas[(n+1)%27]}
+
+	n, err = karana(rise)
+	if err != nil {
+		return nil, err
+	}
+	end, err = limbEnd(rise, karana)
+	if err != nil {
+		return nil, err
+	}
+	p.Karana = PanchangaLimb{Name: karanaName(n), Number: n + 1, End: utDate(end), Next: karanaName((n + 1) % 60)}
+
+	switch p.Tithi.Name {
+	case "Ekadashi", "Purnima", "Amavasya":
+		p.Flags = append(p.Flags, p.Tithi.Name)
+	}
+
+	return p, nil
+}
+
+// PanchangaHandler returns the panchanga of a date at a location, from the
+// sunrise following the local mean midnight. The ayanamsa defaults to
+// Lahiri.
+func PanchangaHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("ayanamsa") == "" {
+		q.Set("ayanamsa", "lahiri")
+	}
+
+	c := &ChartInfo{}
+	if err := parseSidereal(q, "", c); err != nil {
+		fmt.Printf("error: %v\n", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	geopos := [3]float64{queryFloat(q, "lon", 0), queryFloat(q, "lat", 0), queryFloat(q, "alt", 0)}
+	midnight := julday(queryInt(q, "year", 1970), queryInt(q, "month", 1), queryInt(q, "day", 1), 0) - geopos[0]/360
+
+	rise, err := sunriseAfter(midnight, geopos)
+	if err != nil {
+		fmt.Printf("error: %v\n", err)
+		http.Error(w, err.Error(), sunErrorStatus(err))
+		return
+	}
+	next, err := sunriseAfter(rise+0.01, geopos)
+	if err != nil {
+		fmt.Printf("error: %v\n", err)
+		http.Error(w, err.Error(), sunErrorStatus(err))
+		return
+	}
+
+	name, value, err := ayanamsa(rise, c.sidmode)
+	if err != nil {
+		fmt.Printf("error: %v\n", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	p, err := panchanga(rise, next, geopos[0], value)
+	if err != nil {
+		fmt.Printf("error: %v\n", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	p.Ayanamsa, p.AyanamsaValue = name, value
+	p.Lon, p.Lat = geopos[0], geopos[1]
+
+	writeXML(w, p)
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func Test_tithiName(t *testing.T) {
+	tests := []struct {
+		n          int
+		want       string
+		wantPaksha string
+	}{
+		{0, "Pratipada", "Shukla"},
+		{10, "Ekadashi", "Shukla"},
+		{14, "Purnima", "Shukla"},
+		{15, "Pratipada", "Krishna"},
+		{25, "Ekadashi", "Krishna"},
+		{29, "Amavasya", "Krishna"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			got, paksha := tithiName(tt.n)
+			if got != tt.want || paksha != tt.wantPaksha {
+				t.Errorf("tithiName() = %v, %v, want %v, %v", got, paksha, tt.want, tt.wantPaksha)
+			}
+		})
+	}
+}
+
+func Test_karanaName(t *testing.T) {
+	tests := []struct {
+		n    int
+		want string
+	}{
+		{0, "Kimstughna"},
+		{1, "Bava"},
+		{7, "Vishti"},
+		{8, "Bava"},
+		{56, "Vishti"},
+		{57, "Shakuni"},
+		{59, "Naga"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := karanaName(tt.n); got != tt.want {
+				t.Errorf("karanaName(%v) = %v, want %v", tt.n, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPanchangaHandler(t *testing.T) {
+	sweSetEphePath("swe")
+	defer sweClose()
+
+	tests := []struct {
+		name string
+		url  string
+		want []string
+	}{
+		{
+			name: "Ekadashi in Delhi",
+			url:  "/panchanga?year=2024&month=1&day=21&lat=28.61&lon=77.21",
+			want: []string{
+				`<Tithi name="Ekadashi" number="11" paksha="Shukla" end="2024-01-21T13:57:37Z" next="Dvadashi">`,
+				`<Vara name="Ravivara" number="1" lord="Sun"`,
+				`<Nakshatra name="Rohini" number="4" pada="1" lord="Moon"`,
+				`<Karana name="Vanija" number="21"`,
+				`<Flag>Ekadashi</Flag>`,
+			},
+		},
+		{
+			name: "Amavasya in Delhi",
+			url:  "/panchanga?year=2024&month=1&day=11&lat=28.61&lon=77.21",
+			want: []string{`<Flag>Amavasya</Flag>`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("GET", tt.url, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rr := httptest.NewRecorder()
+			handler := http.HandlerFunc(PanchangaHandler)
+			handler.ServeHTTP(rr, req)
+
+			for _, want := range tt.want {
+				if !strings.Contains(rr.Body.String(), want) {
+					t.Errorf("handler returned wrong xml: got %v want %v", rr.Body.String(), want)
+				}
+			}
+		})
+	}
+
+	// The sun doesn't rise at midwinter in Svalbard
+	req, err := http.NewRequest("GET", "/panchanga?year=2019&month=12&day=21&lat=78&lon=15", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(PanchangaHandler).ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("handler returned status %v for the polar night, want %v", rr.Code, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+/*
+#include "swephexp.h"
+*/
+import "C"
+
+// Distances to the Sun, in degrees of longitude, of the cazimi, the
+// combustion and the bodies under the beams
+const (
+	cazimiOrb     = 17.0 / 60
+	combustionOrb = 8.5
+	underBeamsOrb = 17
+)
+
+// Magnitude of the faintest bodies visible to the naked eye
+const nakedEyeMagnitude = 6
+
+// hasPhenomena tells whether the phenomena of a body can be computed, the
+// nodes, the apogees and the Earth have none
+func hasPhenomena(id int) bool {
+	return id <= C.SE_PLUTO || (id >= C.SE_CHIRON && id <= C.SE_VESTA)
+}
+
+// combustion classifies the distance of a body to the Sun: cazimi, in the
+// heart of the Sun, combust, under the beams or free
+func combustion(distance float64) string {
+	switch {
+	case distance <= cazimiOrb:
+		return "cazimi"
+	case distance <= combustionOrb:
+		return "combust"
+	case distance <= underBeamsOrb:
+		return "under_the_beams"
+	}
+	return "free"
+}
+
+// appearance tells whether a body rises before the Sun, as a morning star,
+// or sets after it, as an evening star
+func appearance(lon, sun float64) string {
+	if normalize(lon-sun) > 180 {
+		return "morning_star"
+	}
+	return "evening_star"
+}
+
+// addPhenomena gives the phase angle, the illuminated fraction, the
+// elongation, the apparent diameter and the apparent magnitude of the
+// bodies of a chart, and their phase relative to the Sun. A body is
+// invisible to the naked eye under the beams of the Sun or when fainter
+// than the sixth magnitude.
+func addPhenomena(c *ChartInfo) error {
+	sun, err := calcUT(c.julday, C.SE_SUN, 0)
+	if err != nil {
+		return err
+	}
+
+	for i, b := range c.Bodies {
+		if !hasPhenomena(b.ID) {
+			continue
+		}
+
+		attr, err := phenoUT(c.julday, b.ID, 0)
+		if err != nil {
+			return err
+		}
+		body := &c.Bodies[i]
+		body.PhaseAngle, body.Illumination, body.Elongation = attr[0], attr[1], attr[2]
+		body.Diameter, body.Magnitude = attr[3], attr[4]
+
+		if b.ID == C.SE_SUN {
+			continue
+		}
+
+		xx, err := calcUT(c.julday, b.ID, 0)
+		if err != nil {
+			return err
+		}
+		body.Combustion = combustion(angleDiff(xx[0], sun[0]))
+		body.Appearance = appearance(xx[0], sun[0])
+		body.Visibility = "visible"
+		if body.Combustion != "free" || body.Magnitude > nakedEyeMagnitude {
+			body.Visibility = "invisible"
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func Test_combustion(t *testing.T) {
+	tests := []struct {
+		distance float64
+		want     string
+	}{
+		{0.1, "cazimi"},
+		{0.3, "combust"},
+		{8.5, "combust"},
+		{12, "under_the_beams"},
+		{17.5, "free"},
+	}
+	for _, tt := range tests {
+		if got := combustion(tt.distance); got != tt.want {
+			t.Errorf("combustion(%v) = %v, want %v", tt.distance, got, tt.want)
+		}
+	}
+}
+
+func Test_appearance(t *testing.T) {
+	tests := []struct {
+		lon, sun float64
+		want     string
+	}{
+		{lon: 40, sun: 56, want: "morning_star"},
+		{lon: 91, sun: 56, want: "evening_star"},
+		{lon: 350, sun: 10, want: "morning_star"},
+		{lon: 20, sun: 350, want: "evening_star"},
+	}
+	for _, tt := range tests {
+		if got := appearance(tt.lon, tt.sun); got != tt.want {
+			t.Errorf("appearance(%v, %v) = %v, want %v", tt.lon, tt.sun, got, tt.want)
+		}
+	}
+}
+
+func TestChartInfoHandlerPhenomena(t *testing.T) {
+	sweSetEphePath("swe")
+	defer sweClose()
+
+	req := httptest.NewRequest("GET", "/chartinfo?year=1980&month=5&day=17&time=12&lat=48.85&lon=2.35&display=0,2,3,8,10&pheno=1", nil)
+	w := httptest.NewRecorder()
+	ChartInfoHandler(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("ChartInfoHandler() status = %v", w.Code)
+	}
+
+	out := w.Body.String()
+	for _, s := range []string{
+		`id="0" apparent_diameter="0.527`,
+		`id="2" phase_angle="16.85`,
+		`combustion="combust" appearance="evening_star" visibility="invisible"></Mercury>`,
+		`illumination="0.202`,
+		`combustion="free" appearance="evening_star" visibility="visible"></Venus>`,
+		`appearance="morning_star" visibility="invisible"></Neptune>`,
+		`id="10"></MeanNode>`,
+	} {
+		if !strings.Contains(out, s) {
+			t.Errorf("ChartInfoHandler() output missing %s", s)
+		}
+	}
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+/*
+#include "swephexp.h"
+*/
+import "C"
+
+// Rectification is the root node of the rectification output
+type Rectification struct {
+	XMLName    xml.Name    `xml:"rectification"`
+	Candidates []Candidate `xml:"Candidate"`
+	Start      string      `xml:"start,attr"`
+	End        string      `xml:"end,attr"`
+	Step       float64     `xml:"step,attr"`
+	Events     int         `xml:"events,attr"`
+}
+
+// Candidate is a possible birth time, with its score and the hits to its
+// angles supporting it
+type Candidate struct {
+	Time      float64 `xml:"time,attr"`
+	Date      string  `xml:"date,attr"`
+	Score     float64 `xml:"score,attr"`
+	Ascendant string  `xml:"ascendant,attr"`
+	MC        string  `xml:"mc,attr"`
+	Hits      []Hit   `xml:"Hit"`
+}
+
+// Hit is a direction, a progression or a transit to an angle close to the
+// date of an event
+type Hit struct {
+	Technique string  `xml:"technique,attr"`
+	Event     string  `xml:"event,attr"`
+	Body      string  `xml:"body,attr"`
+	Angle     string  `xml:"angle,attr"`
+	Aspect    string  `xml:"aspect,attr"`
+	Orb       float64 `xml:"orb,attr"`
+}
+
+// Weights of the techniques in the score of a candidate
+var rectificationWeights = map[string]float64{
+	"direction":   3,
+	"progression": 2,
+	"transit":     1,
+}
+
+// The most candidate times a scan may cast, one a minute over a day
+const maxRectificationCandidates = 24*60 + 1
+
+// Settings of a rectification scan
+type rectificationSettings struct {
+	directions     directionSettings
+	directionOrb   float64
+	orb            float64
+	events         []float64
+	transitBodies  []int
+	progressBodies []int
+}
+
+// parseEvents reads a comma separated list of dates of events, as
+// 2006-01-02 or 2006-01-02T15:04 in UT
+func parseEvents(s string) ([]float64, error) {
+	var events []float64
+	for _, e := range strings.Split(s, ",") {
+		t, err := time.Parse("2006-01-02T15:04", e)
+		if err != nil {
+			if t, err = time.Parse("2006-01-02", e); err != nil {
+				return nil, err
+			}
+		}
+		events = append(events, julday(int64(t.Year()), int64(t.Month()), int64(t.Day()),
+			float64(t.Hour())+float64(t.Minute())/60))
+	}
+	return events, nil
+}
+
+// angleHits finds the hard aspects of bodies at the given longitudes to the
+// ascendant and the MC of a chart
+func angleHits(c *ChartInfo, technique, event string, lons map[string]float64, orb float64) []Hit {
+	var hits []Hit
+	for name, lon := range lons {
+		for i, angle := range []string{"Ascendant", "MC"} {
+			for _, a := range directionAspects {
+				if math.Mod(a.angle, 90) != 0 {
+					continue
+				}
+				if d := math.Abs(angleDiff(lon, c.ascmc[i]) - a.angle); d <= orb {
+					hits = append(hits, Hit{Technique: technique, Event: event, Body: name, Angle: angle, Aspect: a.name, Orb: d})
+				}
+			}
+		}
+	}
+	return hits
+}
+
+// scoreCandidate casts the chart of a candidate birth time and collects the
+// directions, progressions and transits to its angles at the dates of the
+// events. Each hit adds the weight of its technique, less as the orb grows.
+func scoreCandidate(c *ChartInfo, s rectificationSettings) (*Candidate, error) {
+	if err := castChart(c, s.progressBodies); err != nil {
+		return nil, err
+	}
+
+	ascSign, _ := signOf(c.ascmc[0])
+	mcSign, _ := signOf(c.ascmc[1])
+	cand := &Candidate{Time: c.Time, Date: utDate(c.julday), Ascendant: snames[ascSign], MC: snames[mcSign]}
+
+	d := &Directions{MaxAge: 0}
+	for _, e := range s.events {
+		d.MaxAge = math.Max(d.MaxAge, (e-c.julday)/tropicalYear+s.directionOrb)
+	}
+	if err := primaryDirections(c, s.directions, d, s.progressBodies, []string{"Ascendant", "MC"}); err != nil {
+		return nil, err
+	}
+
+	for _, e := range s.events {
+		event := utDate(e)
+		age := (e - c.julday) / tropicalYear
+
+		for _, dir := range d.Directions {
+			if diff := math.Abs(dir.Age - age); diff <= s.directionOrb {
+				cand.Hits = append(cand.Hits, Hit{Technique: "direction", Event: event, Body: dir.Promissor,
+					Angle: dir.Significator, Aspect: dir.Aspect, Orb: diff * s.directions.key})
+			}
+		}
+
+		// Secondary progressions, a day for a year
+		progressed := make(map[string]float64)
+		for _, ipl := range s.progressBodies {
+			xx, err := calcUT(c.julday+age, ipl, 0)
+			if err != nil {
+				return nil, err
+			}
+			progressed[bnames[ipl]] = xx[0]
+		}
+		cand.Hits = append(cand.Hits, angleHits(c, "progression", event, progressed, s.orb)...)
+
+		transits := make(map[string]float64)
+		for _, ipl := range s.transitBodies {
+			xx, err := calcUT(e, ipl, 0)
+			if err != nil {
+				return nil, err
+			}
+			transits[bnames[ipl]] = xx[0]
+		}
+		cand.Hits = append(cand.Hits, angleHits(c, "transit", event, transits, s.orb)...)
+	}
+
+	for _, h := range cand.Hits {
+		orb := s.orb
+		if h.Technique == "direction" {
+			orb = s.directionOrb * s.directions.key
+		}
+		cand.Score += rectificationWeights[h.Technique] * (1 - h.Orb/orb)
+	}
+
+	sort.Slice(cand.Hits, func(i, j int) bool {
+		if cand.Hits[i].Event != cand.Hits[j].Event {
+			return cand.Hits[i].Event < cand.Hits[j].Event
+		}
+		return cand.Hits[i].Orb < cand.Hits[j].Orb
+	})
+
+	return cand, nil
+}
+
+// rectify scans the candidate birth times of a chart from start to end, in
+// hours, every step minutes, and ranks them by score
+func rectify(base *ChartInfo, s rectificationSettings, start, end, step float64) ([]Candidate, error) {
+	var candidates []Candidate
+	for i := 0; start+float64(i)*step/60 <= end; i++ {
+		t := start + float64(i)*step/60
+		c := &ChartInfo{Year: base.Year, Month: base.Month, Day: base.Day, Time: t,
+			Lat: base.Lat, Lon: base.Lon, Hsys: base.Hsys}
+		cand, err := scoreCandidate(c, s)
+		if err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, *cand)
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Score > candidates[j].Score
+	})
+
+	return candidates, nil
+}
+
+// queryRectificationSettings reads the events, the orbs and the settings of
+// the directions
+func queryRectificationSettings(q url.Values) (rectificationSettings, error) {
+	var s rectificationSettings
+
+	ds, _, err := queryDirectionSettings(q)
+	if err != nil {
+		return s, err
+	}
+	s.directions = ds
+
+	if s.events, err = parseEvents(q.Get("events")); err != nil {
+		return s, err
+	}
+
+	s.directionOrb = queryFloat(q, "direction_orb", 1)
+	s.orb = queryFloat(q, "orb", 1)
+	if s.directionOrb <= 0 || s.orb <= 0 {
+		return s, errors.New("the orbs must be positive")
+	}
+	s.progressBodies = []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}
+	s.transitBodies = []int{4, 5, 6, 7, 8, 9}
+
+	return s, nil
+}
+
+// RectificationHandler ranks the candidate birth times of a natal chart,
+// given with the natal_ prefix, between start_time and end_time, every step
+// minutes. The events are dates separated by commas. The primary directions
+// use the method, mode and key parameters of the directions endpoint, within
+// direction_orb years, the progressions and the transits are hard aspects
+// within orb degrees. The best candidates are returned, up to limit. A scan
+// casts at most maxRectificationCandidates charts.
+func RectificationHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	s, err := queryRectificationSettings(q)
+	if err != nil {
+		fmt.Printf("error: %v\n", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	base, _, err := parseChartInfo(q, "natal_")
+	if err != nil {
+		fmt.Printf("error: %v\n", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// The candidates are cast from the date and the place only
+	if base.sidereal || base.Derive != "" || base.Relocation != nil {
+		err := errors.New("rectification needs a tropical chart, neither derived nor relocated")
+		fmt.Printf("error: %v\n", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	start := queryFloat(q, "start_time", 0)
+	end := queryFloat(q, "end_time", 24)
+	step := queryFloat(q, "step", 4)
+	if step <= 0 {
+		step = 4
+	}
+	if end <= start {
+		err := errors.New("end_time must be after start_time")
+		fmt.Printf("error: %v\n", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if (end-start)*60/step >= maxRectificationCandidates {
+		err := fmt.Errorf("too many candidate times, at most %d", maxRectificationCandidates)
+		fmt.Printf("error: %v\n", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	candidates, err := rectify(base, s, start, end, step)
+	if err != nil {
+		fmt.Printf("error: %v\n", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	limit := int(queryInt(q, "limit", 10))
+	if limit > 0 && limit < len(candidates) {
+		candidates = candidates[:limit]
+	}
+
+	jd := julday(base.Year, base.Month, base.Day, 0)
+	writeXML(w, &Rectification{
+		Candidates: candidates,
+		Start:      utDate(jd + start/24),
+		End:        utDate(jd + end/24),
+		Step:       step,
+		Events:     len(s.events),
+	})
+}
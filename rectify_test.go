@@ -0,0 +1,123 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func Test_parseEvents(t *testing.T) {
+	tests := []struct {
+		name    string
+		events  string
+		want    []string
+		wantErr bool
+	}{
+		{name: "Dates", events: "2001-06-10,2008-09-15", want: []string{"2001-06-10T00:00:00Z", "2008-09-15T00:00:00Z"}},
+		{name: "Date and time", events: "2015-01-20T13:45", want: []string{"2015-01-20T13:45:00Z"}},
+		{name: "Bad date", events: "20/01/2015", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseEvents(tt.events)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseEvents() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("parseEvents() = %v, want %v", got, tt.want)
+			}
+			for i, jd := range got {
+				if utDate(jd) != tt.want[i] {
+					t.Errorf("parseEvents()[%d] = %v, want %v", i, utDate(jd), tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func Test_angleHits(t *testing.T) {
+	c := &ChartInfo{ascmc: [10]float64{100, 10}}
+	hits := angleHits(c, "transit", "", map[string]float64{"Saturn": 190.5, "Mars": 55}, 1)
+	if len(hits) != 2 {
+		t.Fatalf("angleHits() = %v, want two hits of Saturn", hits)
+	}
+	for _, h := range hits {
+		want := map[string]string{"Ascendant": "Square", "MC": "Opposition"}[h.Angle]
+		if h.Body != "Saturn" || h.Aspect != want || h.Orb != 0.5 {
+			t.Errorf("angleHits() hit = %v, want a %s of Saturn to the %s", h, want, h.Angle)
+		}
+	}
+}
+
+func TestRectificationHandler(t *testing.T) {
+	sweSetEphePath("swe")
+	defer sweClose()
+
+	tests := []struct {
+		name   string
+		query  string
+		status int
+		want   []string
+	}{
+		{
+			name:   "Ranked candidates",
+			query:  "natal_year=1980&natal_month=5&natal_day=17&natal_lat=48.85&natal_lon=2.35&events=2001-06-10,2008-09-15,2015-01-20&limit=3",
+			status: http.StatusOK,
+			want:   []string{`<rectification start="1980-05-17T00:00:00Z" end="1980-05-18T00:00:00Z" step="4" events="3">`, `<Candidate time="0.9333333333333333" date="1980-05-17T00:56:00Z"`, `technique="direction"`},
+		},
+		{
+			name:   "Bad event",
+			query:  "natal_year=1980&events=tomorrow",
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "Reversed range",
+			query:  "natal_year=1980&events=2001-06-10&start_time=12&end_time=6",
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "Too many candidates",
+			query:  "natal_year=1980&events=2001-06-10&step=0.001",
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "Sidereal chart",
+			query:  "natal_year=1980&events=2001-06-10&natal_ayanamsa=lahiri",
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "Relocated chart",
+			query:  "natal_year=1980&events=2001-06-10&natal_relocate_lat=40.71&natal_relocate_lon=-74",
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "Zero orb",
+			query:  "natal_year=1980&events=2001-06-10&orb=0",
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "Negative direction orb",
+			query:  "natal_year=1980&events=2001-06-10&direction_orb=-1",
+			status: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/rectification?"+tt.query, nil)
+			w := httptest.NewRecorder()
+			RectificationHandler(w, req)
+			if w.Code != tt.status {
+				t.Fatalf("RectificationHandler() status = %v, want %v", w.Code, tt.status)
+			}
+			for _, s := range tt.want {
+				if !strings.Contains(w.Body.String(), s) {
+					t.Errorf("RectificationHandler() output missing %s", s)
+				}
+			}
+			if n := strings.Count(w.Body.String(), "<Candidate "); tt.status == http.StatusOK && n != 3 {
+				t.Errorf("RectificationHandler() returned %d candidates, want 3", n)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+/*
+#include "swephexp.h"
+*/
+import "C"
+
+// Relocation is the second location of a relocated chart, for which the
+// houses and the angles are cast. The birth moment and location stay those
+// of the chart.
+type Relocation struct {
+	Lat       float64 `xml:"lat,attr"`
+	Lon       float64 `xml:"lon,attr"`
+	City      string  `xml:"city,attr,omitempty"`
+	Tz        string  `xml:"tz,attr,omitempty"`
+	LocalDate string  `xml:"local_date,attr,omitempty"`
+}
+
+// parseRelocation reads relocate_lat and relocate_lon, the location of a
+// relocated chart, with relocate_city and relocate_tz. The time zone gives
+// the local date of the birth moment at that location.
+func parseRelocation(q url.Values, prefix string, c *ChartInfo) error {
+	if q.Get(prefix+"relocate_lat") == "" && q.Get(prefix+"relocate_lon") == "" {
+		return nil
+	}
+	if q.Get(prefix+"relocate_lat") == "" || q.Get(prefix+"relocate_lon") == "" {
+		return errors.New("relocated charts need both relocate_lat and relocate_lon")
+	}
+
+	r := &Relocation{City: q.Get(prefix + "relocate_city"), Tz: q.Get(prefix + "relocate_tz")}
+	var err error
+	if r.Lat, err = strconv.ParseFloat(q.Get(prefix+"relocate_lat"), 64); err != nil {
+		return err
+	}
+	if r.Lon, err = strconv.ParseFloat(q.Get(prefix+"relocate_lon"), 64); err != nil {
+		return err
+	}
+
+	if r.Tz != "" {
+		loc, err := time.LoadLocation(r.Tz)
+		if err != nil {
+			return err
+		}
+		year, month, day, hour := revjul(julday(c.Year, c.Month, c.Day, c.Time), C.SE_GREG_CAL)
+		ut := time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC).Add(time.Duration(hour * float64(time.Hour)))
+		r.LocalDate = ut.Round(time.Second).In(loc).Format(time.RFC3339)
+	}
+
+	c.Relocation = r
+	return nil
+}
+
+// location returns where the houses and the angles of a chart are cast, the
+// relocated location if any
+func (c *ChartInfo) location() (lat, lon float64) {
+	if c.Relocation != nil {
+		return c.Relocation.Lat, c.Relocation.Lon
+	}
+	return c.Lat, c.Lon
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func Test_parseRelocation(t *testing.T) {
+	tests := []struct {
+		name          string
+		query         string
+		want          *Relocation
+		wantErr       bool
+		wantLocalDate string
+	}{
+		{name: "Not relocated", query: "lat=48.85&lon=2.35"},
+		{name: "Relocated", query: "relocate_lat=40.71&relocate_lon=-74", want: &Relocation{Lat: 40.71, Lon: -74}},
+		{name: "Local date", query: "relocate_lat=35.68&relocate_lon=139.69&relocate_tz=Asia/Tokyo",
+			want: &Relocation{Lat: 35.68, Lon: 139.69, Tz: "Asia/Tokyo", LocalDate: "1980-05-17T21:00:00+09:00"}},
+		{name: "Missing longitude", query: "relocate_lat=40.71", wantErr: true},
+		{name: "Missing latitude", query: "relocate_lon=-74", wantErr: true},
+		{name: "Unknown time zone", query: "relocate_lat=40.71&relocate_lon=-74&relocate_tz=Mars/Olympus", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, _ := url.ParseQuery(tt.query)
+			c := &ChartInfo{Year: 1980, Month: 5, Day: 17, Time: 12, Lat: 48.85, Lon: 2.35}
+			err := parseRelocation(q, "", c)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseRelocation() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if (c.Relocation == nil) != (tt.want == nil) || (c.Relocation != nil && *c.Relocation != *tt.want) {
+				t.Errorf("parseRelocation() = %v, want %v", c.Relocation, tt.want)
+			}
+			lat, lon := c.location()
+			if tt.want != nil && (lat != tt.want.Lat || lon != tt.want.Lon) {
+				t.Errorf("location() = %v, %v, want the relocated location", lat, lon)
+			}
+			if tt.want == nil && (lat != c.Lat || lon != c.Lon) {
+				t.Errorf("location() = %v, %v, want the birth location", lat, lon)
+			}
+		})
+	}
+}
+
+func TestChartInfoHandlerRelocation(t *testing.T) {
+	sweSetEphePath("swe")
+	defer sweClose()
+
+	req := httptest.NewRequest("GET", "/chartinfo?year=1980&month=5&day=17&time=12&lat=48.85&lon=2.35&display=0&relocate_lat=40.71&relocate_lon=-74&relocate_city=New%20York&relocate_tz=America/New_York", nil)
+	w := httptest.NewRecorder()
+	ChartInfoHandler(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("ChartInfoHandler() status = %v", w.Code)
+	}
+
+	for _, s := range []string{
+		`time="12" lat="48.85" lon="2.35"`,
+		`<Ascendant sign_name="Cancer" degree_ut="92.94`,
+		`<Sun sign_name="Taurus" dist="0" degree_ut="56.72`,
+		`<relocation lat="40.71" lon="-74" city="New York" tz="America/New_York" local_date="1980-05-17T08:00:00-04:00">`,
+	} {
+		if !strings.Contains(w.Body.String(), s) {
+			t.Errorf("ChartInfoHandler() output missing %s", s)
+		}
+	}
+
+	for _, query := range []string{"relocate_lat=40.71", "relocate_lon=-74", "relocate_lat=north&relocate_lon=-74"} {
+		req = httptest.NewRequest("GET", "/chartinfo?"+query, nil)
+		w = httptest.NewRecorder()
+		ChartInfoHandler(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("ChartInfoHandler() status = %v for %v", w.Code, query)
+		}
+	}
+}
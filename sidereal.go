@@ -0,0 +1,117 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"net/url"
+	"strconv"
+	"unsafe"
+)
+
+/*
+#include "swephexp.h"
+*/
+import "C"
+
+// Common ayanamsas by name, the others can be given by their swisseph number
+var ayanamsas = map[string]int{
+	"fagan_bradley": C.SE_SIDM_FAGAN_BRADLEY,
+	"lahiri":        C.SE_SIDM_LAHIRI,
+	"deluce":        C.SE_SIDM_DELUCE,
+	"raman":         C.SE_SIDM_RAMAN,
+	"krishnamurti":  C.SE_SIDM_KRISHNAMURTI,
+	"yukteshwar":    C.SE_SIDM_YUKTESHWAR,
+	"true_citra":    C.SE_SIDM_TRUE_CITRA,
+	"true_revati":   C.SE_SIDM_TRUE_REVATI,
+	"true_pushya":   C.SE_SIDM_TRUE_PUSHYA,
+}
+
+// Names of the 27 nakshatras, starting at 0° Aries sidereal
+var nakshatras = []string{"Ashwini", "Bharani", "Krittika", "Rohini",
+	"Mrigashira", "Ardra", "Punarvasu", "Pushya", "Ashlesha", "Magha",
+	"PurvaPhalguni", "UttaraPhalguni", "Hasta", "Chitra", "Swati", "Vishakha",
+	"Anuradha", "Jyeshtha", "Mula", "PurvaAshadha", "UttaraAshadha",
+	"Shravana", "Dhanishta", "Shatabhisha", "PurvaBhadrapada",
+	"UttaraBhadrapada", "Revati"}
+
+// Lords of the nakshatras, repeating every nine nakshatras
+var nakshatraLords = []string{"Ketu", "Venus", "Sun", "Moon", "Mars", "Rahu",
+	"Jupiter", "Saturn", "Mercury"}
+
+// Extent of a nakshatra, 13°20'
+const nakshatraSpan = 360.0 / 27
+
+// parseSidereal reads the ayanamsa of a sidereal chart from a query string,
+// by name or by swisseph number
+func parseSidereal(q url.Values, prefix string, c *ChartInfo) error {
+	name := q.Get(prefix + "ayanamsa")
+	if name == "" {
+		return nil
+	}
+
+	if mode, ok := ayanamsas[name]; ok {
+		c.sidereal, c.sidmode = true, mode
+		return nil
+	}
+
+	mode, err := strconv.Atoi(name)
+	if err != nil || mode < 0 || mode >= C.SE_NSIDM_PREDEF {
+		return errors.New("unknown ayanamsa: " + name)
+	}
+	c.sidereal, c.sidmode = true, mode
+
+	return nil
+}
+
+// ayanamsa returns the name and the value of an ayanamsa at jd. The
+// nutation in longitude is added so that the ayanamsa can be subtracted
+// from the true tropical positions, like swisseph does with SEFLG_SIDEREAL.
+func ayanamsa(jd float64, sidmode int) (string, float64, error) {
+	var daya C.double
+	serr := make([]byte, 256)
+
+	mu.Lock()
+	C.swe_set_sid_mode(C.int32(sidmode), 0, 0)
+	ret := C.swe_get_ayanamsa_ex_ut(C.double(jd), C.SEFLG_SWIEPH, &daya, (*C.char)(unsafe.Pointer(&serr[0])))
+	name := C.GoString(C.swe_get_ayanamsa_name(C.int32(sidmode)))
+	mu.Unlock()
+
+	if ret < 0 {
+		return "", 0, sweError(serr)
+	}
+
+	nut, err := calcUT(jd, C.SE_ECL_NUT, 0)
+	if err != nil {
+		return "", 0, err
+	}
+
+	return name, float64(daya) + nut[2], nil
+}
+
+// nakshatraOf returns the nakshatra, the pada and the lord of a sidereal
+// longitude
+func nakshatraOf(degreeUt float64) (string, int, string) {
+	degreeUt = normalize(degreeUt)
+	n := int(degreeUt / nakshatraSpan)
+	pada := int(math.Mod(degreeUt, nakshatraSpan)/(nakshatraSpan/4)) + 1
+	return nakshatras[n], pada, nakshatraLords[n%len(nakshatraLords)]
+}
+
+// siderealChart shifts a chart by its ayanamsa and gives the nakshatra of
+// every body
+func siderealChart(c *ChartInfo) error {
+	name, value, err := ayanamsa(c.julday, c.sidmode)
+	if err != nil {
+		return err
+	}
+	c.Ayanamsa = name
+	c.AyanamsaValue = value
+
+	transformChart(c, func(lon float64) float64 { return normalize(lon - value) })
+
+	for i, b := range c.Bodies {
+		c.Bodies[i].Nakshatra, c.Bodies[i].Pada, c.Bodies[i].NakshatraLord = nakshatraOf(b.DegreeUt)
+	}
+
+	return nil
+}
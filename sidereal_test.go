@@ -0,0 +1,81 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func Test_nakshatraOf(t *testing.T) {
+	tests := []struct {
+		name     string
+		degreeUt float64
+		want     string
+		wantPada int
+		wantLord string
+	}{
+		{name: "Start of Aries", degreeUt: 0, want: "Ashwini", wantPada: 1, wantLord: "Ketu"},
+		{name: "Bharani 2nd pada", degreeUt: 17, want: "Bharani", wantPada: 2, wantLord: "Venus"},
+		{name: "Magha restarts the lords", degreeUt: 120.5, want: "Magha", wantPada: 1, wantLord: "Ketu"},
+		{name: "End of Pisces", degreeUt: 359.9, want: "Revati", wantPada: 4, wantLord: "Mercury"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, pada, lord := nakshatraOf(tt.degreeUt)
+			if got != tt.want || pada != tt.wantPada || lord != tt.wantLord {
+				t.Errorf("nakshatraOf() = %v, %v, %v, want %v, %v, %v", got, pada, lord, tt.want, tt.wantPada, tt.wantLord)
+			}
+		})
+	}
+}
+
+func TestChartInfoHandlerSidereal(t *testing.T) {
+	sweSetEphePath("swe")
+	defer sweClose()
+
+	tests := []struct {
+		name string
+		url  string
+		want string
+	}{
+		{
+			name: "Lahiri by name",
+			url:  "/chartinfo?year=2019&month=2&day=18&time=16.083334&lat=48&lon=2&display=0,1&ayanamsa=lahiri",
+			want: `<Moon sign_name="Cancer" dist="0" degree_ut="111.447540430059" degree="21.447540430058993" sign="3" retrograde="false" id="1" nakshatra="Ashlesha" pada="2" nakshatra_lord="Mercury">`,
+		},
+		{
+			name: "Fagan-Bradley by number",
+			url:  "/chartinfo?year=2019&month=2&day=18&time=16.083334&lat=48&lon=2&display=0,1&ayanamsa=0",
+			want: `ayanamsa="Fagan/Bradley"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("GET", tt.url, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rr := httptest.NewRecorder()
+			handler := http.HandlerFunc(ChartInfoHandler)
+			handler.ServeHTTP(rr, req)
+
+			if !strings.Contains(rr.Body.String(), tt.want) {
+				t.Errorf("handler returned wrong xml: got %v want %v", rr.Body.String(), tt.want)
+			}
+		})
+	}
+
+	for _, url := range []string{"/chartinfo?ayanamsa=bogus", "/chartinfo?ayanamsa=999"} {
+		req, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(ChartInfoHandler).ServeHTTP(rr, req)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%v returned status %v, want %v", url, rr.Code, http.StatusBadRequest)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"unsafe"
+)
+
+/*
+#include "swephexp.h"
+*/
+import "C"
+
+// sweError converts an error message filled by the Swiss Ephemeris
+func sweError(serr []byte) error {
+	return errors.New(C.GoString((*C.char)(unsafe.Pointer(&serr[0]))))
+}
+
+// julday returns the julian day in UT for a gregorian date and a decimal hour
+func julday(year, month, day int64, hour float64) float64 {
+	return float64(C.swe_julday(C.int(year), C.int(month), C.int(day), C.double(hour), C.SE_GREG_CAL))
+}
+
+// revjul returns the date and decimal hour of a julian day in the gregorian
+// or the julian calendar
+func revjul(jd float64, gregflag int) (year, month, day int, hour float64) {
+	var y, m, d C.int
+	var h C.double
+	C.swe_revjul(C.double(jd), C.int(gregflag), &y, &m, &d, &h)
+	return int(y), int(m), int(d), float64(h)
+}
+
+// formatDate formats a julian day as an ISO 8601 date in UT, rounded to the
+// second, in the gregorian or the julian calendar
+func formatDate(jd float64, gregflag int) string {
+	jd = math.Floor(jd*86400+0.5) / 86400
+	year, month, day, hour := revjul(jd, gregflag)
+	secs := int(math.Round(hour*3600)) % 86400
+
+	sign := ""
+	if year < 0 {
+		sign = "-"
+		year = -year
+	}
+
+	return fmt.Sprintf("%s%04d-%02d-%02dT%02d:%02d:%02dZ", sign, year, month, day,
+		secs/3600, secs/60%60, secs%60)
+}
+
+// utDate formats a julian day as an ISO 8601 date in UT
+func utDate(jd float64) string {
+	return formatDate(jd, C.SE_GREG_CAL)
+}
+
+// calcUT computes the position of a body, see swe_calc_ut
+func calcUT(jd float64, ipl int, iflag int) ([6]float64, error) {
+	var xx [6]C.double
+	serr := make([]byte, 256)
+
+	mu.Lock()
+	ret := C.swe_calc_ut(C.double(jd), C.int32(ipl), C.int32(iflag), &xx[0], (*C.char)(unsafe.Pointer(&serr[0])))
+	mu.Unlock()
+
+	var out [6]float64
+	for i := range xx {
+		out[i] = float64(xx[i])
+	}
+
+	if ret < 0 {
+		return out, sweError(serr)
+	}
+
+	return out, nil
+}
+
+// riseTrans finds the next rising, setting or meridian transit of a body
+// after jd, see swe_rise_trans. A star name takes precedence over ipl. The
+// returned bool is false when the body does not cross the horizon.
+func riseTrans(jd float64, ipl int, star string, rsmi int, geopos [3]float64, atpress, attemp float64) (float64, bool, error) {
+	var tret [10]C.double
+	serr := make([]byte, 256)
+
+	var cstar *C.char
+	if star != "" {
+		buf := make([]byte, 2*C.SE_MAX_STNAME)
+		copy(buf, star)
+		cstar = (*C.char)(unsafe.Pointer(&buf[0]))
+	}
+
+	mu.Lock()
+	ret := C.swe_rise_trans(C.double(jd), C.int32(ipl), cstar, C.SEFLG_SWIEPH, C.int32(rsmi),
+		(*C.double)(unsafe.Pointer(&geopos[0])), C.double(atpress), C.double(attemp),
+		&tret[0], (*C.char)(unsafe.Pointer(&serr[0])))
+	mu.Unlock()
+
+	if ret == -2 {
+		return 0, false, nil
+	}
+	if ret < 0 {
+		return 0, false, sweError(serr)
+	}
+
+	return float64(tret[0]), true, nil
+}
+
+// azalt converts ecliptic coordinates to the azimuth, true altitude and
+// apparent altitude of a point for an observer, see swe_azalt. The azimuth
+// is measured from the south point, westward.
+func azalt(jd float64, geopos [3]float64, atpress, attemp float64, lon, lat, dist float64) [3]float64 {
+	xin := [3]C.double{C.double(lon), C.double(lat), C.double(dist)}
+	var xaz [3]C.double
+
+	mu.Lock()
+	C.swe_azalt(C.double(jd), C.SE_ECL2HOR, (*C.double)(unsafe.Pointer(&geopos[0])),
+		C.double(atpress), C.double(attemp), &xin[0], &xaz[0])
+	mu.Unlock()
+
+	return [3]float64{float64(xaz[0]), float64(xaz[1]), float64(xaz[2])}
+}
+
+// fixstarUT computes the position of a fixed star, see swe_fixstar_ut. It
+// returns the name of the star as found in the catalog.
+func fixstarUT(star string, jd float64, iflag int) ([6]float64, string, error) {
+	var xx [6]C.double
+	serr := make([]byte, 256)
+	buf := make([]byte, 2*C.SE_MAX_STNAME)
+	copy(buf, star)
+
+	mu.Lock()
+	ret := C.swe_fixstar_ut((*C.char)(unsafe.Pointer(&buf[0])), C.double(jd), C.int32(iflag), &xx[0], (*C.char)(unsafe.Pointer(&serr[0])))
+	mu.Unlock()
+
+	var out [6]float64
+	for i := range xx {
+		out[i] = float64(xx[i])
+	}
+
+	if ret < 0 {
+		return out, "", sweError(serr)
+	}
+
+	return out, C.GoString((*C.char)(unsafe.Pointer(&buf[0]))), nil
+}
+
+// sidTime returns the Greenwich apparent sidereal time in hours, see
+// swe_sidtime
+func sidTime(jd float64) float64 {
+	mu.Lock()
+	defer mu.Unlock()
+	return float64(C.swe_sidtime(C.double(jd)))
+}
+
+// phenoUT computes the phase angle, the illuminated fraction, the
+// elongation, the apparent diameter and the apparent magnitude of a body,
+// see swe_pheno_ut
+func phenoUT(jd float64, ipl int, iflag int) ([20]float64, error) {
+	var attr [20]C.double
+	serr := make([]byte, 256)
+
+	mu.Lock()
+	ret := C.swe_pheno_ut(C.double(jd), C.int32(ipl), C.int32(iflag), &attr[0], (*C.char)(unsafe.Pointer(&serr[0])))
+	mu.Unlock()
+
+	var out [20]float64
+	for i := range attr {
+		out[i] = float64(attr[i])
+	}
+
+	if ret < 0 {
+		return out, sweError(serr)
+	}
+
+	return out, nil
+}
+
+// nodApsUT computes the positions of the ascending node, the descending
+// node, the perihelion and the aphelion of a planet, see swe_nod_aps_ut
+func nodApsUT(jd float64, ipl int, iflag int, method int) ([4][6]float64, error) {
+	var xx [4][6]C.double
+	serr := make([]byte, 256)
+
+	mu.Lock()
+	ret := C.swe_nod_aps_ut(C.double(jd), C.int32(ipl), C.int32(iflag), C.int32(method),
+		&xx[0][0], &xx[1][0], &xx[2][0], &xx[3][0], (*C.char)(unsafe.Pointer(&serr[0])))
+	mu.Unlock()
+
+	var out [4][6]float64
+	for i := range xx {
+		for j := range xx[i] {
+			out[i][j] = float64(xx[i][j])
+		}
+	}
+
+	if ret < 0 {
+		return out, sweError(serr)
+	}
+
+	return out, nil
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"math"
+	"net/http"
+)
+
+/*
+#include "swephexp.h"
+*/
+import "C"
+
+// TimeLords is the root node of the time lords output
+type TimeLords struct {
+	XMLName     xml.Name     `xml:"timelords"`
+	Profections []Profection `xml:"profections>Profection"`
+	Firdaria    []Firdar     `xml:"firdaria>Firdar"`
+	Releasing   []Releasing  `xml:"releasing>Releasing"`
+	Sect        string       `xml:"sect,attr"`
+	Start       string       `xml:"start,attr"`
+	End         string       `xml:"end,attr"`
+}
+
+// Profection is a year of life, activating the sign that many signs after
+// the ascendant, with its lord as the lord of the year
+type Profection struct {
+	Age      int          `xml:"age,attr"`
+	SignName string       `xml:"sign_name,attr"`
+	Sign     int          `xml:"sign,attr"`
+	House    int          `xml:"house,attr"`
+	Lord     string       `xml:"lord,attr"`
+	Start    string       `xml:"start,attr"`
+	End      string       `xml:"end,attr"`
+	Months   []Profection `xml:"Month"`
+}
+
+// Firdar is a firdaria period, or one of its seven sub-periods
+type Firdar struct {
+	Lord     string   `xml:"lord,attr"`
+	Start    string   `xml:"start,attr"`
+	End      string   `xml:"end,attr"`
+	Years    float64  `xml:"years,attr"`
+	SubLords []Firdar `xml:"Firdar"`
+	start    float64
+	end      float64
+}
+
+// Releasing lists the zodiacal releasing periods from a lot
+type Releasing struct {
+	Lot      string            `xml:"lot,attr"`
+	SignName string            `xml:"sign_name,attr"`
+	Periods  []ReleasingPeriod `xml:"Period"`
+}
+
+// ReleasingPeriod is a period of zodiacal releasing at some level. Angle
+// gives the angular signs from the Lot of Fortune, the tenth being the
+// peak. Loosing marks the period starting a loosing of the bond.
+type ReleasingPeriod struct {
+	Level    int               `xml:"level,attr"`
+	SignName string            `xml:"sign_name,attr"`
+	Sign     int               `xml:"sign,attr"`
+	Lord     string            `xml:"lord,attr"`
+	Start    string            `xml:"start,attr"`
+	End      string            `xml:"end,attr"`
+	Angle    int               `xml:"angle,attr,omitempty"`
+	Peak     bool              `xml:"peak,attr,omitempty"`
+	Loosing  bool              `xml:"loosing,attr,omitempty"`
+	Periods  []ReleasingPeriod `xml:"Period"`
+}
+
+// Length of the tropical year in days
+const tropicalYear = 365.24219
+
+type firdarLord struct {
+	lord  string
+	years float64
+}
+
+// The firdaria of diurnal charts, nocturnal charts start with the Moon and
+// keep the nodes at the end
+var diurnalFirdaria = []firdarLord{{"Sun", 10}, {"Venus", 8}, {"Mercury", 13},
+	{"Moon", 9}, {"Saturn", 11}, {"Jupiter", 12}, {"Mars", 7},
+	{"NorthNode", 3}, {"SouthNode", 2}}
+var nocturnalFirdaria = []firdarLord{{"Moon", 9}, {"Saturn", 11},
+	{"Jupiter", 12}, {"Mars", 7}, {"Sun", 10}, {"Venus", 8}, {"Mercury", 13},
+	{"NorthNode", 3}, {"SouthNode", 2}}
+
+// Minor years of the signs, the years of their lords, used by zodiacal
+// releasing
+var minorYears = []float64{15, 8, 20, 25, 19, 20, 8, 15, 12, 27, 30, 12}
+
+// Length in days of a unit of each level of zodiacal releasing: years of
+// 360 days, months of 30 days, then 2.5 days and 5 hours
+var releasingUnits = []float64{360, 30, 2.5, 2.5 / 12}
+
+// sunReturn finds when the Sun reaches a longitude, starting near jd
+func sunReturn(jd float64, lon float64) (float64, error) {
+	for i := 0; i < 10; i++ {
+		xx, err := calcUT(jd, C.SE_SUN, 0)
+		if err != nil {
+			return 0, err
+		}
+		d := normalize(lon - xx[0])
+		if d > 180 {
+			d -= 360
+		}
+		if math.Abs(d) < 1e-7 {
+			break
+		}
+		jd += d / 0.9856
+	}
+	return jd, nil
+}
+
+// profections lists the annual profections overlapping a time range, from
+// one solar return to the next, each divided in twelve monthly profections
+// starting with the Sun back to its natal position plus a multiple of 30°
+func profections(c *ChartInfo, sun float64, start, end float64) ([]Profection, error) {
+	var ps []Profection
+	asc, _ := signOf(c.ascmc[0])
+
+	for age := int(math.Max(0, math.Floor((start-c.julday)/tropicalYear)-1)); ; age++ {
+		from, err := sunReturn(c.julday+float64(age)*tropicalYear, sun)
+		if err != nil {
+			return nil, err
+		}
+		to, err := sunReturn(c.julday+float64(age+1)*tropicalYear, sun)
+		if err != nil {
+			return nil, err
+		}
+		if age == 0 {
+			from = c.julday
+		}
+		if from >= end {
+			break
+		}
+		if to <= start {
+			continue
+		}
+
+		sign := (asc + age) % 12
+		p := Profection{Age: age, SignName: snames[sign], Sign: sign, House: age%12 + 1,
+			Lord: domiciles[sign], Start: utDate(from), End: utDate(to)}
+
+		for month := 0; month < 12; month++ {
+			mfrom, mto := from, to
+			if month > 0 {
+				if mfrom, err = sunReturn(from+float64(month)*30.4, sun+float64(month)*30); err != nil {
+					return nil, err
+				}
+			}
+			if month < 11 {
+				if mto, err = sunReturn(from+float64(month+1)*30.4, sun+float64(month+1)*30); err != nil {
+					return nil, err
+				}
+			}
+			if mto <= start || mfrom >= end {
+				continue
+			}
+			msign := (sign + month) % 12
+			p.Months = append(p.Months, Profection{Age: age, SignName: snames[msign], Sign: msign,
+				House: (age+month)%12 + 1, Lord: domiciles[msign], Start: utDate(mfrom), End: utDate(mto)})
+		}
+
+		ps = append(ps, p)
+	}
+
+	return ps, nil
+}
+
+// firdaria lists the firdaria overlapping a time range. The periods of the
+// planets have seven sub-periods, starting with their lord and following
+// the Chaldean order.
+func firdaria(jd float64, diurnal bool, start, end float64) []Firdar {
+	sequence := nocturnalFirdaria
+	if diurnal {
+		sequence = diurnalFirdaria
+	}
+
+	var fs []Firdar
+	t := jd
+	for _, l := range sequence {
+		f := Firdar{Lord: l.lord, Years: l.years, start: t, end: t + l.years*tropicalYear}
+		f.Start, f.End = utDate(f.start), utDate(f.end)
+		t = f.end
+
+		if f.end <= start || f.start >= end {
+			continue
+		}
+
+		if l.lord != "NorthNode" && l.lord != "SouthNode" {
+			sub := f.start
+			lord := l.lord
+			for i := 0; i < 7; i++ {
+				s := Firdar{Lord: lord, Years: l.years / 7, start: sub, end: sub + (f.end-f.start)/7}
+				s.Start, s.End = utDate(s.start), utDate(s.end)
+				sub = s.end
+				lord = nextHourRuler(lord)
+				if s.end <= start || s.start >= end {
+					continue
+				}
+				f.SubLords = append(f.SubLords, s)
+			}
+		}
+
+		fs = append(fs, f)
+	}
+
+	return fs
+}
+
+// releasingPeriods lists the zodiacal releasing periods of a level starting
+// from a sign, until the end of the parent period. When the twelve signs
+// have been run through, the bond is loosed and the periods jump to the sign
+// opposite the one that would come next.
+func releasingPeriods(sign, fortune, level, levels int, from, to, start, end float64) []ReleasingPeriod {
+	var ps []ReleasingPeriod
+
+	t := from
+	for i := 0; t < to; i++ {
+		loosing := false
+		if i > 0 && i%12 == 0 && level > 1 {
+			sign = (sign + 6) % 12
+			loosing = true
+		}
+
+		length := minorYears[sign] * releasingUnits[level-1]
+		p := ReleasingPeriod{Level: level, SignName: snames[sign], Sign: sign, Lord: domiciles[sign], Loosing: loosing}
+		pfrom, pto := t, math.Min(t+length, to)
+		p.Start, p.End = utDate(pfrom), utDate(pto)
+
+		switch angle := (sign-fortune+12)%12 + 1; angle {
+		case 1, 4, 7, 10:
+			p.Angle = angle
+			p.Peak = angle == 10
+		}
+
+		if pto > start && pfrom < end {
+			if level < levels {
+				p.Periods = releasingPeriods(sign, fortune, level+1, levels, pfrom, pto, start, end)
+			}
+			ps = append(ps, p)
+		}
+
+		t += length
+		sign = (sign + 1) % 12
+	}
+
+	return ps
+}
+
+// TimeLordsHandler lists the profections, the firdaria and the zodiacal
+// releasing from Spirit and Fortune of a natal chart, given with the natal_
+// prefix, over a time range. The releasing goes down to the level given by
+// levels, 2 by default.
+func TimeLordsHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	start, end := queryRange(q)
+
+	c, display, err := parseChartInfo(q, "natal_")
+	if err != nil {
+		fmt.Printf("error: %v\n", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := castChart(c, display); err != nil {
+		fmt.Printf("error: %v\n", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := addLots(c, lotFormulas[:2]); err != nil {
+		fmt.Printf("error: %v\n", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	t := &TimeLords{Sect: c.Sect, Start: utDate(start), End: utDate(end)}
+
+	sun, err := calcUT(c.julday, C.SE_SUN, 0)
+	if err != nil {
+		fmt.Printf("error: %v\n", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	t.Profections, err = profections(c, sun[0], start, end)
+	if err != nil {
+		fmt.Printf("error: %v\n", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	t.Firdaria = firdaria(c.julday, c.Sect == "day", start, end)
+
+	levels := int(queryInt(q, "levels", 2))
+	if levels < 1 || levels > len(releasingUnits) {
+		levels = 2
+	}
+	fortune := c.Lots.Lots[0].Sign
+	for _, lot := range c.Lots.Lots {
+		t.Releasing = append(t.Releasing, Releasing{
+			Lot:      lot.XMLName.Local,
+			SignName: lot.SignName,
+			Periods:  releasingPeriods(lot.Sign, fortune, 1, levels, c.julday, c.julday+200*tropicalYear, start, end),
+		})
+	}
+
+	writeXML(w, t)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func Test_firdaria(t *testing.T) {
+	tests := []struct {
+		name     string
+		diurnal  bool
+		age      float64
+		wantLord string
+		wantSubs []string
+	}{
+		{name: "Diurnal Moon", diurnal: true, age: 33.5, wantLord: "Moon", wantSubs: []string{"Saturn"}},
+		{name: "Nocturnal Saturn", diurnal: false, age: 12, wantLord: "Saturn", wantSubs: []string{"Jupiter"}},
+		{name: "Diurnal nodes", diurnal: true, age: 71, wantLord: "NorthNode"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jd := tt.age * tropicalYear
+			fs := firdaria(0, tt.diurnal, jd, jd+1)
+			if len(fs) != 1 || fs[0].Lord != tt.wantLord {
+				t.Fatalf("firdaria() = %v, want %v", fs, tt.wantLord)
+			}
+			var subs []string
+			for _, s := range fs[0].SubLords {
+				subs = append(subs, s.Lord)
+			}
+			if strings.Join(subs, ",") != strings.Join(tt.wantSubs, ",") {
+				t.Errorf("firdaria() sub-periods = %v, want %v", subs, tt.wantSubs)
+			}
+		})
+	}
+}
+
+func Test_releasingPeriods(t *testing.T) {
+	// Capricorn lasts 27 years, its sub-periods run through the twelve signs
+	// in 211 months and then loose the bond to Cancer
+	ps := releasingPeriods(9, 0, 1, 2, 0, 27*360, 0, 27*360)
+	if len(ps) != 1 || ps[0].SignName != "Capricorn" || !ps[0].Peak {
+		t.Fatalf("releasingPeriods() = %v, want a Capricorn peak", ps)
+	}
+
+	subs := ps[0].Periods
+	if subs[12].SignName != "Cancer" || !subs[12].Loosing {
+		t.Errorf("releasingPeriods() 13th sub-period = %v, want a loosing of the bond to Cancer", subs[12])
+	}
+	if subs[11].Loosing {
+		t.Errorf("releasingPeriods() 12th sub-period loosing the bond")
+	}
+}
+
+func TestTimeLordsHandler(t *testing.T) {
+	sweSetEphePath("swe")
+	defer sweClose()
+
+	req, err := http.NewRequest("GET", "/timelords?natal_year=1990&natal_month=5&natal_day=10&natal_time=14&natal_lat=48.85&natal_lon=2.35&year=2024&month=1&day=1&end_year=2025&end_month=1&end_day=1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(TimeLordsHandler)
+	handler.ServeHTTP(rr, req)
+
+	for _, want := range []string{
+		`<timelords sect="day"`,
+		`<Profection age="34" sign_name="Cancer" sign="3" house="11" lord="Moon" start="2024-05-09T19:27:41Z"`,
+		`<Firdar lord="Moon" start="2021-05-10T02:11:22Z"`,
+		`<Releasing lot="Fortune" sign_name="Aries">`,
+		`<Period level="2" sign_name="Capricorn" sign="9" lord="Saturn" start="2022-10-19T14:00:00Z" end="2025-01-06T14:00:00Z" angle="10" peak="true">`,
+	} {
+		if !strings.Contains(rr.Body.String(), want) {
+			t.Errorf("handler returned wrong xml: got %v want %v", rr.Body.String(), want)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"encoding/xml"
+	"errors"
+	"math"
+	"net/url"
+)
+
+/*
+#include "swephexp.h"
+*/
+import "C"
+
+// UnknownTime describes what can still be said of a chart when the birth
+// time is unknown: the range of the Moon over the day, the bodies changing
+// sign and the aspects only holding for part of the day
+type UnknownTime struct {
+	Mode        string           `xml:"mode,attr"`
+	Start       string           `xml:"start,attr"`
+	End         string           `xml:"end,attr"`
+	Moon        MoonRange        `xml:"Moon"`
+	SignChanges []SignChange     `xml:"sign_changes>SignChange"`
+	Aspects     []PossibleAspect `xml:"aspects>Aspect"`
+}
+
+// MoonRange gives the longitudes of the Moon at the start and the end of
+// the day
+type MoonRange struct {
+	From     float64 `xml:"from,attr"`
+	To       float64 `xml:"to,attr"`
+	FromSign string  `xml:"from_sign,attr"`
+	ToSign   string  `xml:"to_sign,attr"`
+}
+
+// SignChange is a body entering a sign during the day
+type SignChange struct {
+	Body string `xml:"body,attr"`
+	From string `xml:"from,attr"`
+	To   string `xml:"to,attr"`
+	Date string `xml:"date,attr"`
+}
+
+// PossibleAspect is an aspect holding only from start to end, so it depends
+// on the birth time
+type PossibleAspect struct {
+	XMLName xml.Name
+	Body1   string `xml:"body1,attr"`
+	Body2   string `xml:"body2,attr"`
+	Start   string `xml:"start,attr"`
+	End     string `xml:"end,attr"`
+}
+
+// Number of positions computed over the day, every 15 minutes
+const unknownTimeSamples = 24*4 + 1
+
+// parseUnknownTime reads unknown_time=noon or sunrise, and replaces the time
+// of the chart by the local mean noon or the sunrise of the day. There is no
+// sunrise chart on a day the sun doesn't rise.
+func parseUnknownTime(q url.Values, prefix string, c *ChartInfo) error {
+	mode := q.Get(prefix + "unknown_time")
+	switch mode {
+	case "":
+		return nil
+	case "1", "noon":
+		mode = "noon"
+	case "sunrise":
+	default:
+		return errors.New("unknown unknown_time mode: " + mode)
+	}
+
+	c.UnknownTime = &UnknownTime{Mode: mode}
+	c.Time = 12 - c.Lon/15
+
+	if mode == "sunrise" {
+		midnight := julday(c.Year, c.Month, c.Day, 0) - c.Lon/360
+		rise, err := sunriseAfter(midnight, [3]float64{c.Lon, c.Lat, 0})
+		if err != nil {
+			return err
+		}
+		if rise >= midnight+1 {
+			return errNoSunrise
+		}
+		c.Time = (rise - julday(c.Year, c.Month, c.Day, 0)) * 24
+	}
+
+	return nil
+}
+
+// omitUnknownAngles drops the houses and the angles from the output of a
+// chart cast for an unknown time
+func omitUnknownAngles(c *ChartInfo) {
+	if c.UnknownTime != nil {
+		c.AscMCs, c.Houses = nil, nil
+	}
+}
+
+// bodyLongitude returns the longitude of a body of the chart
+func bodyLongitude(c *ChartInfo, jd float64, id int) (float64, error) {
+	xx, err := c.bodyPosition(jd, id, 0)
+	return c.derived(xx[0]), err
+}
+
+// addUnknownTime finds what changes over the day of a chart cast for an
+// unknown time. The houses and the angles are kept for the computations
+// and only omitted from the output by omitUnknownAngles.
+func addUnknownTime(c *ChartInfo) error {
+	u := c.UnknownTime
+
+	start := julday(c.Year, c.Month, c.Day, 0) - c.Lon/360
+	end := start + 1
+	u.Start, u.End = utDate(start), utDate(end)
+
+	from, err := bodyLongitude(c, start, C.SE_MOON)
+	if err != nil {
+		return err
+	}
+	to, err := bodyLongitude(c, end, C.SE_MOON)
+	if err != nil {
+		return err
+	}
+	fromSign, _ := signOf(from)
+	toSign, _ := signOf(to)
+	u.Moon = MoonRange{From: from, To: to, FromSign: snames[fromSign], ToSign: snames[toSign]}
+
+	// Positions of the displayed bodies over the day
+	times := make([]float64, unknownTimeSamples)
+	for i := range times {
+		times[i] = start + float64(i)/float64(unknownTimeSamples-1)
+	}
+	lons := make([][]float64, len(c.Bodies))
+	for b, body := range c.Bodies {
+		lons[b] = make([]float64, len(times))
+		for i, jd := range times {
+			if lons[b][i], err = bodyLongitude(c, jd, body.ID); err != nil {
+				return err
+			}
+		}
+	}
+
+	for b, body := range c.Bodies {
+		for i := 1; i < len(times); i++ {
+			before, _ := signOf(lons[b][i-1])
+			after, _ := signOf(lons[b][i])
+			if before == after {
+				continue
+			}
+			id := body.ID
+			jd, err := limbEnd(times[i-1], func(jd float64) (int, error) {
+				lon, err := bodyLongitude(c, jd, id)
+				sign, _ := signOf(lon)
+				return sign, err
+			})
+			if err != nil {
+				return err
+			}
+			u.SignChanges = append(u.SignChanges, SignChange{Body: body.XMLName.Local,
+				From: snames[before], To: snames[after], Date: utDate(jd)})
+		}
+	}
+
+	for b1, body1 := range c.Bodies {
+		for b2 := b1 + 1; b2 < len(c.Bodies); b2++ {
+			body2 := c.Bodies[b2]
+			for _, s := range aspectsettings {
+				first, last := -1, -1
+				for i := range times {
+					if math.Abs(angleDiff(lons[b1][i], lons[b2][i])-s.delta) < s.orb {
+						if first < 0 {
+							first = i
+						}
+						last = i
+					}
+				}
+				if first < 0 || (first == 0 && last == len(times)-1) {
+					continue
+				}
+
+				u.Aspects = append(u.Aspects, PossibleAspect{XMLName: xml.Name{Local: s.title},
+					Body1: body1.XMLName.Local, Body2: body2.XMLName.Local,
+					Start: utDate(times[first]), End: utDate(times[last])})
+
+				for i, a := range c.Aspects {
+					if a.XMLName.Local == s.title &&
+						((a.Body1 == body1.XMLName.Local && a.Body2 == body2.XMLName.Local) ||
+							(a.Body1 == body2.XMLName.Local && a.Body2 == body1.XMLName.Local)) {
+						c.Aspects[i].Uncertain = true
+					}
+				}
+			}
+		}
+	}
+
+	return nil
+}
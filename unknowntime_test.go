@@ -0,0 +1,118 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func Test_parseUnknownTime(t *testing.T) {
+	sweSetEphePath("swe")
+	defer sweClose()
+
+	tests := []struct {
+		name     string
+		query    string
+		wantMode string
+		wantTime float64
+		wantErr  bool
+	}{
+		{name: "Known time", query: "time=8", wantTime: 8},
+		{name: "Noon", query: "time=8&lon=30&unknown_time=1", wantMode: "noon", wantTime: 10},
+		{name: "Sunrise", query: "lat=48.85&lon=2.35&year=1980&month=5&day=17&unknown_time=sunrise", wantMode: "sunrise", wantTime: 4.10},
+		{name: "Unknown mode", query: "unknown_time=sunset", wantErr: true},
+		{name: "Polar day", query: "lat=78&lon=15&year=2019&month=6&day=21&unknown_time=sunrise", wantErr: true},
+		{name: "Polar night", query: "lat=78&lon=15&year=2019&month=12&day=21&unknown_time=sunrise", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, _ := url.ParseQuery(tt.query)
+			c := &ChartInfo{Year: queryInt(q, "year", 1970), Month: queryInt(q, "month", 1), Day: queryInt(q, "day", 1),
+				Time: queryFloat(q, "time", 0), Lat: queryFloat(q, "lat", 0), Lon: queryFloat(q, "lon", 0)}
+			err := parseUnknownTime(q, "", c)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseUnknownTime() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if math.Abs(c.Time-tt.wantTime) > 0.01 {
+				t.Errorf("parseUnknownTime() time = %v, want %v", c.Time, tt.wantTime)
+			}
+			if (c.UnknownTime == nil && tt.wantMode != "") || (c.UnknownTime != nil && c.UnknownTime.Mode != tt.wantMode) {
+				t.Errorf("parseUnknownTime() mode = %v, want %v", c.UnknownTime, tt.wantMode)
+			}
+		})
+	}
+}
+
+func TestChartInfoHandlerUnknownTime(t *testing.T) {
+	sweSetEphePath("swe")
+	defer sweClose()
+
+	req := httptest.NewRequest("GET", "/chartinfo?year=1980&month=5&day=17&lat=48.85&lon=2.35&display=0,1,2,3,4,5&unknown_time=sunrise", nil)
+	w := httptest.NewRecorder()
+	ChartInfoHandler(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("ChartInfoHandler() status = %v", w.Code)
+	}
+
+	out := w.Body.String()
+	for _, s := range []string{
+		`<unknown_time mode="sunrise"`,
+		`from_sign="Gemini" to_sign="Cancer"`,
+		`<SignChange body="Moon" from="Gemini" to="Cancer" date="1980-05-17T00:51`,
+		`<Conjunction body1="Venus" body2="Moon"`,
+		`uncertain="true"`,
+	} {
+		if !strings.Contains(out, s) {
+			t.Errorf("ChartInfoHandler() output missing %s", s)
+		}
+	}
+	for _, s := range []string{"<AscMC", "<House"} {
+		if strings.Contains(out, s) {
+			t.Errorf("ChartInfoHandler() output contains %s", s)
+		}
+	}
+}
+
+func TestChartInfoHandlerUnknownTimeSections(t *testing.T) {
+	sweSetEphePath("swe")
+	defer sweClose()
+
+	req := httptest.NewRequest("GET", "/chartinfo?year=1980&month=5&day=17&lat=48.85&lon=2.35&unknown_time=1&lots=1", nil)
+	w := httptest.NewRecorder()
+	ChartInfoHandler(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("ChartInfoHandler() with lots status = %v, want %v", w.Code, http.StatusBadRequest)
+	}
+
+	req = httptest.NewRequest("GET", "/chartinfo?year=1980&month=5&day=17&lat=48.85&lon=2.35&unknown_time=1&dignities=1&midpoints=1", nil)
+	w = httptest.NewRecorder()
+	ChartInfoHandler(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("ChartInfoHandler() with dignities status = %v", w.Code)
+	}
+
+	out := w.Body.String()
+	for _, s := range []string{"<AscMC", "<House", "<Almuten", `"Ascendant"`, `"MC"`} {
+		if strings.Contains(out, s) {
+			t.Errorf("ChartInfoHandler() output contains %s", s)
+		}
+	}
+	if !strings.Contains(out, "<dignities") {
+		t.Errorf("ChartInfoHandler() output has no dignities")
+	}
+
+	for _, query := range []string{"unknown_time=bogus", "lat=78&lon=15&year=2019&month=6&day=21&unknown_time=sunrise"} {
+		req = httptest.NewRequest("GET", "/chartinfo?"+query, nil)
+		w = httptest.NewRecorder()
+		ChartInfoHandler(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("ChartInfoHandler() status = %v for %v, want %v", w.Code, query, http.StatusBadRequest)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"math"
+	"net/http"
+)
+
+// Vargas is the root node of the divisional charts output
+type Vargas struct {
+	XMLName       xml.Name `xml:"vargas"`
+	Bodies        []Body   `xml:"bodies>Body"`
+	Vargas        []Varga  `xml:"Varga"`
+	Ayanamsa      string   `xml:"ayanamsa,attr"`
+	AyanamsaValue float64  `xml:"ayanamsa_value,attr"`
+	Year          int64    `xml:"year,attr"`
+	Month         int64    `xml:"month,attr"`
+	Day           int64    `xml:"day,attr"`
+	Time          float64  `xml:"time,attr"`
+	Lat           float64  `xml:"lat,attr"`
+	Lon           float64  `xml:"lon,attr"`
+}
+
+// Varga is a divisional chart, with the sign of the ascendant and of every
+// body
+type Varga struct {
+	Name       string           `xml:"name,attr"`
+	Title      string           `xml:"title,attr"`
+	Placements []VargaPlacement `xml:",any"`
+}
+
+// VargaPlacement is the sign of a body or the ascendant in a varga
+type VargaPlacement struct {
+	XMLName  xml.Name
+	SignName string `xml:"sign_name,attr"`
+	Sign     int    `xml:"sign,attr"`
+}
+
+// The shodashavarga, the sixteen divisional charts of Parashara
+var shodashavarga = []struct {
+	name  string
+	title string
+	sign  func(sign int, degree float64) int
+}{
+	{"D1", "Rasi", func(s int, d float64) int { return s }},
+	{"D2", "Hora", hora},
+	{"D3", "Drekkana", func(s int, d float64) int { return s + 4*part(d, 3) }},
+	{"D4", "Chaturthamsa", func(s int, d float64) int { return s + 3*part(d, 4) }},
+	{"D7", "Saptamsa", func(s int, d float64) int { return s + oddEven(s, 0, 6) + part(d, 7) }},
+	{"D9", "Navamsa", func(s int, d float64) int { return s*9 + part(d, 9) }},
+	{"D10", "Dasamsa", func(s int, d float64) int { return s + oddEven(s, 0, 8) + part(d, 10) }},
+	{"D12", "Dwadasamsa", func(s int, d float64) int { return s + part(d, 12) }},
+	{"D16", "Shodasamsa", func(s int, d float64) int { return quality(s, 0, 4, 8) + part(d, 16) }},
+	{"D20", "Vimsamsa", func(s int, d float64) int { return quality(s, 0, 8, 4) + part(d, 20) }},
+	{"D24", "Chaturvimsamsa", func(s int, d float64) int { return oddEven(s, 4, 3) + part(d, 24) }},
+	{"D27", "Saptavimsamsa", func(s int, d float64) int { return s*27 + part(d, 27) }},
+	{"D30", "Trimsamsa", trimsamsa},
+	{"D40", "Khavedamsa", func(s int, d float64) int { return oddEven(s, 0, 6) + part(d, 40) }},
+	{"D45", "Akshavedamsa", func(s int, d float64) int { return quality(s, 0, 4, 8) + part(d, 45) }},
+	{"D60", "Shashtiamsa", func(s int, d float64) int { return s + part(d, 60) }},
+}
+
+// Returns the division of a sign containing a degree, for n divisions
+func part(degree float64, n int) int {
+	p := int(math.Floor(degree * float64(n) / 30))
+	if p >= n {
+		p = n - 1
+	}
+	return p
+}
+
+// Returns odd for the odd signs, Aries, Gemini..., and even for the others
+func oddEven(sign int, odd, even int) int {
+	if sign%2 == 0 {
+		return odd
+	}
+	return even
+}
+
+// Returns movable, fixed or dual according to the quality of a sign
+func quality(sign int, movable, fixed, dual int) int {
+	return []int{movable, fixed, dual}[sign%3]
+}
+
+// hora gives the first half of the odd signs to the Sun, Leo, and the first
+// half of the even signs to the Moon, Cancer
+func hora(sign int, degree float64) int {
+	if (part(degree, 2) == 0) == (sign%2 == 0) {
+		return 4
+	}
+	return 3
+}
+
+// trimsamsa gives unequal divisions of the signs to Mars, Saturn, Jupiter,
+// Mercury and Venus, in reverse order for the even signs
+func trimsamsa(sign int, degree float64) int {
+	if sign%2 == 0 {
+		switch {
+		case degree < 5:
+			return 0
+		case degree < 10:
+			return 10
+		case degree < 18:
+			return 8
+		case degree < 25:
+			return 2
+		}
+		return 6
+	}
+	switch {
+	case degree < 5:
+		return 1
+	case degree < 12:
+		return 5
+	case degree < 20:
+		return 11
+	case degree < 25:
+		return 9
+	}
+	return 7
+}
+
+// vargaPlacement returns the sign of a sidereal longitude in a varga
+func vargaPlacement(name string, degreeUt float64, sign func(int, float64) int) VargaPlacement {
+	s, d := signOf(degreeUt)
+	v := sign(s, d) % 12
+	return VargaPlacement{XMLName: xml.Name{Local: name}, SignName: snames[v], Sign: v}
+}
+
+// VargasHandler returns the sixteen divisional charts of a sidereal chart,
+// with the nakshatras of the bodies. The ayanamsa defaults to Lahiri and the
+// bodies to the seven planets and the true nodes.
+func VargasHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("ayanamsa") == "" {
+		q.Set("ayanamsa", "lahiri")
+	}
+	if q.Get("display") == "" {
+		q.Set("display", "0,1,2,3,4,5,6,11,24")
+	}
+
+	c, display, err := parseChartInfo(q, "")
+	if err != nil {
+		fmt.Printf("error: %v\n", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := castChart(c, display); err != nil {
+		fmt.Printf("error: %v\n", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	v := &Vargas{
+		Bodies:        c.Bodies,
+		Ayanamsa:      c.Ayanamsa,
+		AyanamsaValue: c.AyanamsaValue,
+		Year:          c.Year,
+		Month:         c.Month,
+		Day:           c.Day,
+		Time:          c.Time,
+		Lat:           c.Lat,
+		Lon:           c.Lon,
+	}
+
+	for _, d := range shodashavarga {
+		varga := Varga{Name: d.name, Title: d.title}
+		varga.Placements = append(varga.Placements, vargaPlacement("Ascendant", c.ascmc[0], d.sign))
+		for _, b := range c.Bodies {
+			varga.Placements = append(varga.Placements, vargaPlacement(b.XMLName.Local, b.DegreeUt, d.sign))
+		}
+		v.Vargas = append(v.Vargas, varga)
+	}
+
+	writeXML(w, v)
+}
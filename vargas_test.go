@@ -0,0 +1,79 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func Test_shodashavarga(t *testing.T) {
+	vargas := make(map[string]func(int, float64) int)
+	for _, d := range shodashavarga {
+		vargas[d.name] = d.sign
+	}
+
+	tests := []struct {
+		name     string
+		varga    string
+		degreeUt float64
+		want     int
+	}{
+		{name: "Hora of early Aries", varga: "D2", degreeUt: 5, want: 4},
+		{name: "Hora of early Taurus", varga: "D2", degreeUt: 35, want: 3},
+		{name: "Drekkana of late Leo", varga: "D3", degreeUt: 145, want: 0},
+		{name: "Saptamsa of Taurus", varga: "D7", degreeUt: 30, want: 7},
+		{name: "Navamsa of early Taurus", varga: "D9", degreeUt: 31, want: 9},
+		{name: "Navamsa of late Pisces", varga: "D9", degreeUt: 359.9, want: 11},
+		{name: "Dasamsa of Cancer", varga: "D10", degreeUt: 91, want: 11},
+		{name: "Shodasamsa of Leo", varga: "D16", degreeUt: 120, want: 4},
+		{name: "Vimsamsa of Taurus", varga: "D20", degreeUt: 30, want: 8},
+		{name: "Trimsamsa of Aries", varga: "D30", degreeUt: 7, want: 10},
+		{name: "Trimsamsa of Taurus", varga: "D30", degreeUt: 37, want: 5},
+		{name: "Shashtiamsa of Gemini", varga: "D60", degreeUt: 75.2, want: 8},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := vargaPlacement("Sun", tt.degreeUt, vargas[tt.varga]); got.Sign != tt.want {
+				t.Errorf("vargaPlacement() = %v, want %v", got.Sign, tt.want)
+			}
+		})
+	}
+}
+
+func TestVargasHandler(t *testing.T) {
+	sweSetEphePath("swe")
+	defer sweClose()
+
+	req, err := http.NewRequest("GET", "/vargas?year=2019&month=2&day=18&time=16.083334&lat=48&lon=2", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(VargasHandler)
+	handler.ServeHTTP(rr, req)
+
+	for _, want := range []string{
+		`<vargas ayanamsa="Lahiri"`,
+		`nakshatra="Dhanishta" pada="4" nakshatra_lord="Mars"`,
+		`<Varga name="D60" title="Shashtiamsa">`,
+	} {
+		if !strings.Contains(rr.Body.String(), want) {
+			t.Errorf("handler returned wrong xml: got %v want %v", rr.Body.String(), want)
+		}
+	}
+	if got := strings.Count(rr.Body.String(), "<Varga "); got != 16 {
+		t.Errorf("handler returned %v vargas, want 16", got)
+	}
+
+	req, err = http.NewRequest("GET", "/vargas?ayanamsa=bogus", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("handler returned status %v for an unknown ayanamsa, want %v", rr.Code, http.StatusBadRequest)
+	}
+}
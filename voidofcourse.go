@@ -0,0 +1,261 @@
+package main
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+/*
+#include "swephexp.h"
+*/
+import "C"
+
+// VoidOfCourse is the root node of the void of course Moon calendar
+type VoidOfCourse struct {
+	XMLName xml.Name     `xml:"voidofcourse"`
+	Periods []VoidPeriod `xml:"Void"`
+	Planets string       `xml:"planets,attr"`
+	Aspects string       `xml:"aspects,attr"`
+	Start   string       `xml:"start,attr"`
+	End     string       `xml:"end,attr"`
+}
+
+// VoidPeriod is the Moon void of course, from its last aspect in a sign to
+// its ingress into the next sign. Without aspect in the sign, the period
+// starts with the ingress into the sign.
+type VoidPeriod struct {
+	Start      string  `xml:"start,attr"`
+	End        string  `xml:"end,attr"`
+	Hours      float64 `xml:"hours,attr"`
+	SignName   string  `xml:"sign_name,attr"`
+	NextSign   string  `xml:"next_sign,attr"`
+	LastAspect string  `xml:"last_aspect,attr,omitempty"`
+	Body       string  `xml:"body,attr,omitempty"`
+	StartJD    float64 `xml:"-"`
+	EndJD      float64 `xml:"-"`
+}
+
+// Settings of the void of course Moon: the bodies and the aspects counted
+type voidSettings struct {
+	planets string
+	bodies  []int
+	aspects []aspectsetting
+}
+
+// The planets counted by default, the traditional ones, and the major
+// aspects
+var traditionalVoidBodies = []int{C.SE_SUN, C.SE_MERCURY, C.SE_VENUS, C.SE_MARS, C.SE_JUPITER, C.SE_SATURN}
+var modernVoidBodies = []int{C.SE_SUN, C.SE_MERCURY, C.SE_VENUS, C.SE_MARS, C.SE_JUPITER, C.SE_SATURN,
+	C.SE_URANUS, C.SE_NEPTUNE, C.SE_PLUTO}
+var majorAspects = []string{"Conjunction", "Sextile", "Square", "Trine", "Opposition"}
+
+// Mean daily motion of the Moon, in degrees
+const moonMotion = 13.176
+
+// queryVoidSettings reads planets=traditional or modern, or the bodies
+// given by display, and the aspects given by name in aspects
+func queryVoidSettings(q url.Values) (voidSettings, error) {
+	s := voidSettings{planets: "traditional", bodies: traditionalVoidBodies}
+
+	switch q.Get("planets") {
+	case "", "traditional":
+	case "modern":
+		s.planets, s.bodies = "modern", modernVoidBodies
+	default:
+		return s, errors.New("unknown planet set: " + q.Get("planets"))
+	}
+
+	if q.Get("display") != "" {
+		d, err := sliceAtoi(strings.Split(q.Get("display"), ","))
+		if err != nil {
+			return s, err
+		}
+		s.planets, s.bodies = "custom", nil
+		for _, id := range d {
+			if id != C.SE_MOON {
+				s.bodies = append(s.bodies, id)
+			}
+		}
+	}
+
+	names := majorAspects
+	if q.Get("aspects") != "" {
+		names = strings.Split(q.Get("aspects"), ",")
+	}
+	for _, name := range names {
+		a, ok := aspectByName(name)
+		if !ok {
+			return s, errors.New("unknown aspect: " + name)
+		}
+		s.aspects = append(s.aspects, a)
+	}
+
+	return s, nil
+}
+
+// defaultVoidSettings counts the major aspects to the traditional planets
+func defaultVoidSettings() voidSettings {
+	s, _ := queryVoidSettings(url.Values{})
+	return s
+}
+
+// moonAt finds when the Moon reaches a longitude, starting near jd
+func moonAt(jd float64, lon float64) (float64, error) {
+	for i := 0; i < 10; i++ {
+		xx, err := calcUT(jd, C.SE_MOON, C.SEFLG_SPEED)
+		if err != nil {
+			return 0, err
+		}
+		d := normalize180(lon - xx[0])
+		if math.Abs(d) < 1e-7 {
+			break
+		}
+		jd += d / xx[3]
+	}
+	return jd, nil
+}
+
+// moonAspectTime finds when the Moon perfects an aspect to a body between
+// two julian days, by bisection to the second. The Moon always moves faster
+// than the planets, so the aspect perfects at most once while the Moon
+// crosses a sign.
+func moonAspectTime(ipl int, angle, from, to float64) (float64, bool, error) {
+	orb := func(jd float64) (float64, error) {
+		moon, err := calcUT(jd, C.SE_MOON, 0)
+		if err != nil {
+			return 0, err
+		}
+		body, err := calcUT(jd, ipl, 0)
+		return normalize180(moon[0] - body[0] - angle), err
+	}
+
+	before, err := orb(from)
+	if err != nil {
+		return 0, false, err
+	}
+	after, err := orb(to)
+	if err != nil {
+		return 0, false, err
+	}
+	if before >= 0 || after < 0 || after-before > 90 {
+		return 0, false, nil
+	}
+
+	for to-from > 1.0/86400 {
+		mid := (from + to) / 2
+		d, err := orb(mid)
+		if err != nil {
+			return 0, false, err
+		}
+		if d < 0 {
+			from = mid
+		} else {
+			to = mid
+		}
+	}
+
+	return to, true, nil
+}
+
+// moonVoidPeriod returns the void of course period of the sign the Moon is
+// in at jd
+func moonVoidPeriod(jd float64, s voidSettings) (VoidPeriod, error) {
+	xx, err := calcUT(jd, C.SE_MOON, 0)
+	if err != nil {
+		return VoidPeriod{}, err
+	}
+	sign, degree := signOf(xx[0])
+
+	ingress, err := moonAt(jd-degree/moonMotion, float64(sign*30))
+	if err != nil {
+		return VoidPeriod{}, err
+	}
+	egress, err := moonAt(jd+(30-degree)/moonMotion, float64(sign*30+30))
+	if err != nil {
+		return VoidPeriod{}, err
+	}
+
+	p := VoidPeriod{SignName: snames[sign], NextSign: snames[(sign+1)%12],
+		StartJD: ingress, EndJD: egress}
+
+	for _, ipl := range s.bodies {
+		for _, a := range s.aspects {
+			angles := []float64{a.delta}
+			if a.delta != 0 && a.delta != 180 {
+				angles = append(angles, -a.delta)
+			}
+			for _, angle := range angles {
+				t, ok, err := moonAspectTime(ipl, angle, ingress, egress)
+				if err != nil {
+					return p, err
+				}
+				if ok && t >= p.StartJD {
+					p.StartJD, p.LastAspect, p.Body = t, a.title, bnames[ipl]
+				}
+			}
+		}
+	}
+
+	p.Start, p.End = utDate(p.StartJD), utDate(p.EndJD)
+	p.Hours = (p.EndJD - p.StartJD) * 24
+	return p, nil
+}
+
+// isVoidOfCourse tells if the Moon is void of course at jd
+func isVoidOfCourse(jd float64, s voidSettings) (bool, VoidPeriod, error) {
+	p, err := moonVoidPeriod(jd, s)
+	return jd >= p.StartJD, p, err
+}
+
+// voidOfCourse lists the void of course periods overlapping a time range
+func voidOfCourse(start, end float64, s voidSettings) ([]VoidPeriod, error) {
+	var periods []VoidPeriod
+	for jd := start; jd < end; {
+		p, err := moonVoidPeriod(jd, s)
+		if err != nil {
+			return periods, err
+		}
+		if p.EndJD > start && p.StartJD < end {
+			periods = append(periods, p)
+		}
+		// Step into the next sign
+		jd = p.EndJD + 1.0/1440
+	}
+	return periods, nil
+}
+
+// VoidOfCourseHandler lists the void of course Moon periods over a time
+// range. The bodies counted are the traditional planets, the modern ones
+// with planets=modern or those given by display, and the aspects are the
+// major ones or those given by name in aspects.
+func VoidOfCourseHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	start, end := queryRange(q)
+
+	s, err := queryVoidSettings(q)
+	if err != nil {
+		fmt.Printf("error: %v\n", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	v := &VoidOfCourse{Planets: s.planets, Start: utDate(start), End: utDate(end)}
+	var names []string
+	for _, a := range s.aspects {
+		names = append(names, a.title)
+	}
+	v.Aspects = strings.Join(names, ",")
+
+	if v.Periods, err = voidOfCourse(start, end, s); err != nil {
+		fmt.Printf("error: %v\n", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeXML(w, v)
+}
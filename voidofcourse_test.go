@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func Test_queryVoidSettings(t *testing.T) {
+	tests := []struct {
+		query   string
+		planets string
+		bodies  int
+		aspects int
+		wantErr bool
+	}{
+		{query: "", planets: "traditional", bodies: 6, aspects: 5},
+		{query: "planets=modern", planets: "modern", bodies: 9, aspects: 5},
+		{query: "display=0,1,4&aspects=conjunction,Opposition", planets: "custom", bodies: 2, aspects: 2},
+		{query: "planets=outer", wantErr: true},
+		{query: "aspects=Quintile", wantErr: true},
+	}
+	for _, tt := range tests {
+		q, _ := url.ParseQuery(tt.query)
+		s, err := queryVoidSettings(q)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("queryVoidSettings(%q) error = %v, wantErr %v", tt.query, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+		if s.planets != tt.planets || len(s.bodies) != tt.bodies || len(s.aspects) != tt.aspects {
+			t.Errorf("queryVoidSettings(%q) = %v %d %d, want %v %d %d", tt.query,
+				s.planets, len(s.bodies), len(s.aspects), tt.planets, tt.bodies, tt.aspects)
+		}
+	}
+}
+
+func Test_isVoidOfCourse(t *testing.T) {
+	sweSetEphePath("swe")
+	defer sweClose()
+
+	tests := []struct {
+		jd   float64
+		want bool
+	}{
+		{julday(2024, 1, 2, 22), false},
+		{julday(2024, 1, 2, 23), true},
+		{julday(2024, 1, 3, 1), false},
+	}
+	for _, tt := range tests {
+		got, p, err := isVoidOfCourse(tt.jd, defaultVoidSettings())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("isVoidOfCourse(%v) = %v, want %v (%v to %v)", utDate(tt.jd), got, tt.want, p.Start, p.End)
+		}
+	}
+}
+
+func TestVoidOfCourseHandler(t *testing.T) {
+	sweSetEphePath("swe")
+	defer sweClose()
+
+	req, err := http.NewRequest("GET", "/voidofcourse?year=2024&month=1&day=1&end_year=2024&end_month=1&end_day=8", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(VoidOfCourseHandler)
+
+	handler.ServeHTTP(rr, req)
+
+	var got VoidOfCourse
+	if err := xml.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got.Periods) != 3 {
+		t.Fatalf("handler returned %v periods, want 3", len(got.Periods))
+	}
+
+	want := VoidPeriod{Start: "2024-01-02T22:13:03Z", End: "2024-01-03T00:46:45Z", SignName: "Virgo",
+		NextSign: "Libra", LastAspect: "Square", Body: "Mars"}
+	p := got.Periods[0]
+	p.Hours = 0
+	if p != want {
+		t.Errorf("handler returned wrong period: got %+v want %+v", p, want)
+	}
+
+	req, err = http.NewRequest("GET", "/voidofcourse?year=2024&aspects=Quintile", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("handler returned status %v for an unknown aspect, want %v", rr.Code, http.StatusBadRequest)
+	}
+}